@@ -0,0 +1,130 @@
+// Package audit reports weak, reused, breached, stale and empty entries of the vault.
+package audit
+
+import (
+	"sort"
+	"time"
+
+	"github.com/koss-null/passy/internal/passgen"
+	"github.com/koss-null/passy/internal/storage"
+)
+
+// Options of the audit.
+type Options struct {
+	// MinEntropy is the strength in bits below which a password is weak.
+	MinEntropy float64
+	// MaxAge is the age after which a password is stale.
+	MaxAge time.Duration
+	// ChangeTimes are the times passwords were set for entries without Folder.Changed.
+	ChangeTimes map[string]time.Time
+	// Breaches is the list of breached passwords to look passwords up in, nil skips the check.
+	Breaches BreachList
+	Now      time.Time
+}
+
+// BreachList tells how many times a password appears in known breaches.
+type BreachList interface {
+	Count(pass string) (int, error)
+}
+
+// Report lists the problems found, keys are in the tree order, stale passwords are the oldest first.
+type Report struct {
+	Reused   []Reused   `json:"reused"`
+	Weak     []Weak     `json:"weak"`
+	Breached []Breached `json:"breached"`
+	Stale    []Stale    `json:"stale"`
+	// Empty are entries without a password or any other value and folders without entries.
+	Empty []string `json:"empty"`
+}
+
+// Reused is a password shared by several keys.
+type Reused struct {
+	Keys []string `json:"keys"`
+}
+
+// Weak is a password weaker than Options.MinEntropy.
+type Weak struct {
+	Key      string   `json:"key"`
+	Entropy  float64  `json:"entropy"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// Breached is a password found in Options.Breaches.
+type Breached struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// Stale is a password unchanged for longer than Options.MaxAge.
+type Stale struct {
+	Key     string    `json:"key"`
+	Changed time.Time `json:"changed"`
+	Days    int       `json:"days"`
+}
+
+// Issues returns the number of problems in the report.
+func (r Report) Issues() int {
+	return len(r.Reused) + len(r.Weak) + len(r.Breached) + len(r.Stale) + len(r.Empty)
+}
+
+// Run audits the vault tree.
+func Run(root *storage.Folder, opts Options) (Report, error) {
+	report := Report{
+		Reused:   []Reused{},
+		Weak:     []Weak{},
+		Breached: []Breached{},
+		Stale:    []Stale{},
+		Empty:    []string{},
+	}
+
+	byPass := make(map[string][]string)
+	var passes []string
+	for _, key := range root.Keys() {
+		entry, _ := root.GetSubFolder(key)
+		if entry.Pass == "" {
+			// an entry may hold only a username, notes, custom fields or an OTP secret
+			if entry.Username == "" && entry.URL == "" && entry.Notes == "" && len(entry.Fields) == 0 && entry.OTP == "" {
+				report.Empty = append(report.Empty, key)
+			}
+			continue
+		}
+
+		if _, ok := byPass[entry.Pass]; !ok {
+			passes = append(passes, entry.Pass)
+		}
+		byPass[entry.Pass] = append(byPass[entry.Pass], key)
+
+		if opts.Breaches != nil {
+			count, err := opts.Breaches.Count(entry.Pass)
+			if err != nil {
+				return Report{}, err
+			}
+			if count > 0 {
+				report.Breached = append(report.Breached, Breached{Key: key, Count: count})
+			}
+		}
+
+		if s := passgen.EstimateStrength(entry.Pass); s.Entropy < opts.MinEntropy {
+			report.Weak = append(report.Weak, Weak{Key: key, Entropy: s.Entropy, Patterns: s.Warnings})
+		}
+
+		changed, known := opts.ChangeTimes[key]
+		if entry.Changed != nil {
+			changed, known = *entry.Changed, true
+		}
+		if age := opts.Now.Sub(changed); known && opts.MaxAge > 0 && age > opts.MaxAge {
+			report.Stale = append(report.Stale, Stale{Key: key, Changed: changed, Days: int(age.Hours() / 24)})
+		}
+	}
+	report.Empty = append(report.Empty, root.EmptyFolders()...)
+
+	for _, pass := range passes {
+		if keys := byPass[pass]; len(keys) > 1 {
+			report.Reused = append(report.Reused, Reused{Keys: keys})
+		}
+	}
+	sort.SliceStable(report.Stale, func(i, j int) bool {
+		return report.Stale[i].Changed.Before(report.Stale[j].Changed)
+	})
+	return report, nil
+}
@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/koss-null/passy/internal/storage"
+)
+
+const strongPass = "Xy7#kLm9$pQr2vTz!aB"
+
+// breachList is a BreachList of known passwords.
+type breachList map[string]int
+
+func (l breachList) Count(pass string) (int, error) {
+	return l[pass], nil
+}
+
+func testTree(t *testing.T, now time.Time) *storage.Folder {
+	t.Helper()
+	root := &storage.Folder{SubFolder: []*storage.Folder{}}
+	add := func(key string) *storage.Folder {
+		t.Helper()
+		f, err := root.Create(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	at := func(days int) *time.Time {
+		changed := now.AddDate(0, 0, -days)
+		return &changed
+	}
+
+	add("mail").Pass, add("mail").Changed = strongPass, at(10)
+	add("web/bank").Pass = strongPass
+	add("web/shop").Pass = "password"
+	add("old").Pass, add("old").Changed = "Qw9!zR4#tY7@uI2$oP", at(500)
+	// entries without a password, but with other values
+	add("wifi").Username = "guest"
+	add("site").URL = "https://example.com"
+	add("codes").Notes = "1234 5678"
+	add("pin").Fields = []storage.Field{{Name: "PIN", Value: "1234", Secret: true}}
+	add("totp").OTP = "otpauth://totp/?secret=JBSWY3DPEHPK3PXP"
+	// a password removed with its history kept
+	add("cleared").History = []storage.PassRecord{{Pass: "old", Time: *at(30)}}
+	add("archive")
+	return root
+}
+
+func TestRun(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	report, err := Run(testTree(t, now), Options{
+		MinEntropy:  60,
+		MaxAge:      365 * 24 * time.Hour,
+		ChangeTimes: map[string]time.Time{"web/shop": now.AddDate(-2, 0, 0), "web/bank": now.AddDate(0, -1, 0), "old": now},
+		Breaches:    breachList{"password": 9659365},
+		Now:         now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []Reused{{Keys: []string{"mail", "web/bank"}}}; !reflect.DeepEqual(report.Reused, want) {
+		t.Errorf("reused %+v, want %+v", report.Reused, want)
+	}
+	if len(report.Weak) != 1 || report.Weak[0].Key != "web/shop" || len(report.Weak[0].Patterns) == 0 {
+		t.Errorf("weak %+v, want web/shop", report.Weak)
+	}
+	if want := []Breached{{Key: "web/shop", Count: 9659365}}; !reflect.DeepEqual(report.Breached, want) {
+		t.Errorf("breached %+v, want %+v", report.Breached, want)
+	}
+	// the oldest first, the entry time wins over the history one
+	want := []Stale{
+		{Key: "web/shop", Changed: now.AddDate(-2, 0, 0), Days: 731},
+		{Key: "old", Changed: now.AddDate(0, 0, -500), Days: 500},
+	}
+	if !reflect.DeepEqual(report.Stale, want) {
+		t.Errorf("stale %+v, want %+v", report.Stale, want)
+	}
+	if want := []string{"cleared", "archive"}; !reflect.DeepEqual(report.Empty, want) {
+		t.Errorf("empty %v, want %v", report.Empty, want)
+	}
+	if report.Issues() != 7 {
+		t.Errorf("%d issues, want 7", report.Issues())
+	}
+}
+
+func TestRunNoBreachList(t *testing.T) {
+	now := time.Now()
+	report, err := Run(testTree(t, now), Options{MinEntropy: 60, Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Breached) != 0 || len(report.Stale) != 0 {
+		t.Errorf("breached %+v and stale %+v without a list and a max age", report.Breached, report.Stale)
+	}
+}
+
+// TestReportJSON checks the field names scripts rely on, lists are empty arrays rather than null.
+func TestReportJSON(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	clean, err := Run(&storage.Folder{}, Options{Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(clean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"reused":[],"weak":[],"breached":[],"stale":[],"empty":[]}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	report := Report{
+		Reused:   []Reused{{Keys: []string{"a", "b"}}},
+		Weak:     []Weak{{Key: "a", Entropy: 12.5, Patterns: []string{"common password"}}, {Key: "b", Entropy: 40}},
+		Breached: []Breached{{Key: "a", Count: 3}},
+		Stale:    []Stale{{Key: "b", Changed: now, Days: 400}},
+		Empty:    []string{"c"},
+	}
+	if data, err = json.Marshal(report); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"reused":[{"keys":["a","b"]}],` +
+		`"weak":[{"key":"a","entropy":12.5,"patterns":["common password"]},{"key":"b","entropy":40}],` +
+		`"breached":[{"key":"a","count":3}],` +
+		`"stale":[{"key":"b","changed":"2024-06-01T00:00:00Z","days":400}],` +
+		`"empty":["c"]}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
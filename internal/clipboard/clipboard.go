@@ -0,0 +1,149 @@
+// Package clipboard puts secrets on the system clipboard and clears them after a timeout.
+package clipboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Clipboard backends, the first available one is used.
+const (
+	WlCopy = "wl-copy"
+	Xclip  = "xclip"
+	// OSC52 asks the terminal to set the clipboard, it works over ssh as well.
+	OSC52 = "osc52"
+)
+
+// HelperCommand is the hidden passy subcommand clearing the clipboard in the background.
+const HelperCommand = "clip-clear"
+
+const ttyPath = "/dev/tty"
+
+// Detect returns the clipboard backend to use.
+func Detect() string {
+	if os.Getenv("WAYLAND_DISPLAY") != "" && hasCommand(WlCopy) {
+		return WlCopy
+	}
+	if os.Getenv("DISPLAY") != "" && hasCommand(Xclip) {
+		return Xclip
+	}
+	return OSC52
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// Copy puts the value on the clipboard and returns the backend it used.
+func Copy(value string) (string, error) {
+	backend := Detect()
+	return backend, set(backend, value)
+}
+
+func set(backend, value string) error {
+	switch backend {
+	case WlCopy:
+		if value == "" {
+			return run(exec.Command(WlCopy, "--clear"), "")
+		}
+		return run(exec.Command(WlCopy), value)
+	case Xclip:
+		return run(exec.Command(Xclip, "-selection", "clipboard"), value)
+	case OSC52:
+		tty, err := os.OpenFile(ttyPath, os.O_WRONLY, 0)
+		if err != nil {
+			return errors.Wrap(err, "no clipboard available: wl-copy and xclip are not found and there is no terminal for OSC 52")
+		}
+		defer tty.Close()
+		_, err = fmt.Fprintf(tty, "\033]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(value)))
+		return err
+	default:
+		return errors.Errorf("unknown clipboard %q", backend)
+	}
+}
+
+// get returns the clipboard content, the terminal clipboard can't be read.
+func get(backend string) (string, error) {
+	var cmd *exec.Cmd
+	switch backend {
+	case WlCopy:
+		cmd = exec.Command("wl-paste", "--no-newline")
+	case Xclip:
+		cmd = exec.Command(Xclip, "-selection", "clipboard", "-o")
+	default:
+		return "", errors.Errorf("the %s clipboard can't be read", backend)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		// an empty clipboard is reported as an error by wl-paste
+		return "", nil
+	}
+	return string(out), nil
+}
+
+func run(cmd *exec.Cmd, stdin string) error {
+	cmd.Stdin = strings.NewReader(stdin)
+	// xclip and wl-copy fork a child which keeps serving the selection and inherits stdout and stderr.
+	// They are left nil to go to /dev/null: any other writer is a pipe Run waits to be closed by that child.
+	cmd.Stdout, cmd.Stderr = nil, nil
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "failed to run %s", cmd.Path)
+	}
+	return nil
+}
+
+// ClearLater starts a detached passy process which clears the clipboard after the timeout
+// if it still holds the value. Only the value hash is passed to the process.
+func ClearLater(backend, value string, timeout time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(value))
+	cmd := exec.Command(exe, HelperCommand, "--clipboard", backend, "--after", timeout.String())
+	detach(cmd)
+	// the hash is written before passy exits, so the pipe is used instead of an io.Reader copied in the background
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "failed to start the clipboard cleaner")
+	}
+	if _, err := stdin.Write(sum[:]); err != nil {
+		return errors.Wrap(err, "failed to start the clipboard cleaner")
+	}
+	if err := stdin.Close(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// ClearAfter waits for the timeout and clears the clipboard if it still holds the value with the hash.
+// The terminal clipboard can't be read, so it's cleared unconditionally.
+func ClearAfter(backend string, sum []byte, timeout time.Duration) error {
+	time.Sleep(timeout)
+
+	if backend != OSC52 {
+		current, err := get(backend)
+		if err != nil {
+			return err
+		}
+		currentSum := sha256.Sum256([]byte(current))
+		if !bytes.Equal(currentSum[:], sum) {
+			return nil
+		}
+	}
+	return set(backend, "")
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package clipboard
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach moves the process to its own process group, so it outlives passy and isn't killed by ^C.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
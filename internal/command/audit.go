@@ -0,0 +1,140 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/audit"
+	"github.com/koss-null/passy/internal/hibp"
+)
+
+const defaultMaxAgeDays = 365
+
+func newAuditCommand() *cobra.Command {
+	var (
+		days     int
+		jsonOut  bool
+		noLookup bool
+		breaches string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Report reused, weak, breached, stale and empty entries",
+		Long: `Passwords are weak below MinEntropy bits from the config (60 by default).
+The age of passwords set by older passy versions is found in the vault history, which may take a while.
+Breached passwords are looked up in a downloaded Have I Been Pwned SHA-1 list, --breaches or BreachFile
+from the config: a file ordered by hash or a directory of range files. Nothing is sent over the network.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleAudit(days, jsonOut, !noLookup, breaches)
+		},
+	}
+	cmd.Flags().IntVar(&days, "days", defaultMaxAgeDays, "passwords unchanged for more days are stale, 0 disables the check")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "print the report as JSON")
+	cmd.Flags().BoolVar(&noLookup, "no-history", false, "don't look up the age of old passwords in the vault history")
+	cmd.Flags().StringVar(&breaches, "breaches", "", "Have I Been Pwned SHA-1 list to look passwords up in")
+
+	return cmd
+}
+
+func handleAudit(days int, jsonOut, lookupHistory bool, breaches string) error {
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+	flds, err := st.Decrypt()
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt")
+	}
+	warnOffline(st)
+
+	opts := audit.Options{
+		MinEntropy: minEntropy(st.Cfg),
+		MaxAge:     time.Duration(days) * 24 * time.Hour,
+		Now:        time.Now(),
+	}
+	if days > 0 && lookupHistory {
+		var unknown []string
+		for _, key := range flds.Keys() {
+			if entry, _ := flds.GetSubFolder(key); entry.Pass != "" && entry.Changed == nil {
+				unknown = append(unknown, key)
+			}
+		}
+		if len(unknown) > 0 {
+			if opts.ChangeTimes, err = st.PassChangeTimes(flds, unknown); err != nil {
+				return errors.Wrap(err, "failed to read the vault history")
+			}
+		}
+	}
+
+	if breaches == "" {
+		breaches = st.Cfg.BreachFile
+	}
+	if breaches != "" {
+		if opts.Breaches, err = hibp.Open(breaches); err != nil {
+			return err
+		}
+	}
+
+	report, err := audit.Run(flds, opts)
+	if err != nil {
+		return errors.Wrap(err, "failed to look up breached passwords")
+	}
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	printAuditReport(report, opts)
+	return nil
+}
+
+func printAuditReport(r audit.Report, opts audit.Options) {
+	if r.Issues() == 0 {
+		fmt.Println("no issues found")
+		return
+	}
+
+	if len(r.Reused) > 0 {
+		fmt.Println("reused passwords:")
+		for _, group := range r.Reused {
+			fmt.Printf("  %s\n", strings.Join(group.Keys, ", "))
+		}
+	}
+	if len(r.Weak) > 0 {
+		fmt.Printf("weak passwords (less than %.0f bits):\n", opts.MinEntropy)
+		for _, w := range r.Weak {
+			patterns := ""
+			if len(w.Patterns) > 0 {
+				patterns = ", " + strings.Join(w.Patterns, ", ")
+			}
+			fmt.Printf("  %s: %.1f bits%s\n", w.Key, w.Entropy, patterns)
+		}
+	}
+	if len(r.Breached) > 0 {
+		fmt.Println("breached passwords:")
+		for _, b := range r.Breached {
+			fmt.Printf("  %s: seen %d times\n", b.Key, b.Count)
+		}
+	}
+	if len(r.Stale) > 0 {
+		fmt.Println("stale passwords:")
+		for _, s := range r.Stale {
+			fmt.Printf("  %s: unchanged for %d days, since %s\n", s.Key, s.Days, s.Changed.Local().Format("2006-01-02"))
+		}
+	}
+	if len(r.Empty) > 0 {
+		fmt.Println("empty entries:")
+		for _, key := range r.Empty {
+			fmt.Printf("  %s\n", key)
+		}
+	}
+	fmt.Printf("%d issues found\n", r.Issues())
+}
@@ -0,0 +1,70 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/clipboard"
+)
+
+const defaultClipTimeout = 45 * time.Second
+
+// clipOptions are the flags of commands printing secrets.
+type clipOptions struct {
+	enabled bool
+	timeout time.Duration
+}
+
+func (o *clipOptions) addFlags(cmd *cobra.Command, requires string) {
+	cmd.Flags().BoolVar(&o.enabled, "clip", false, requires+"copy to the clipboard instead of printing")
+	cmd.Flags().DurationVar(&o.timeout, "clip-timeout", defaultClipTimeout, requires+"clear the clipboard after the timeout, 0 keeps the value")
+}
+
+// copyToClipboard copies the secret and starts the cleaner.
+func copyToClipboard(value string, opts clipOptions) error {
+	backend, err := clipboard.Copy(value)
+	if err != nil {
+		return errors.Wrap(err, "failed to copy to the clipboard")
+	}
+	if opts.timeout <= 0 {
+		fmt.Printf("copied to the clipboard (%s)\n", backend)
+		return nil
+	}
+
+	if err := clipboard.ClearLater(backend, value, opts.timeout); err != nil {
+		return err
+	}
+	fmt.Printf("copied to the clipboard (%s), it will be cleared in %s\n", backend, opts.timeout)
+	return nil
+}
+
+// newClipClearCommand is the detached helper started by copyToClipboard.
+func newClipClearCommand() *cobra.Command {
+	var (
+		backend string
+		after   time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:    clipboard.HelperCommand,
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// the hash of the copied value is passed on stdin
+			sum, err := io.ReadAll(io.LimitReader(os.Stdin, 64))
+			if err != nil {
+				return err
+			}
+			return clipboard.ClearAfter(backend, sum, after)
+		},
+	}
+	cmd.Flags().StringVar(&backend, "clipboard", "", "clipboard to clear")
+	cmd.Flags().DurationVar(&after, "after", defaultClipTimeout, "timeout")
+
+	return cmd
+}
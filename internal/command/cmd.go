@@ -14,19 +14,97 @@ import (
 func helpString() string {
 	return `Usage:
   passy [flags]
+  passy [command]
+
+Commands:
+  rotate-key --new-key <path>  Re-encrypt the vault with a new key and update the config, the key is generated if the file doesn't exist.
+
+  recipients                   Share the vault with several people, each using their own X25519 identity:
+    init --identity <path>     re-encrypt the vault for your identity (generated if the file doesn't exist);
+    keygen <path>              generate an identity and print its public key;
+    use --identity <path>      unlock the vault with your identity once its public key is added;
+    ls                         list recipients;
+    add <name> <public-key>    give the public key access to the vault;
+    rm <name>                  revoke access, the vault is re-encrypted with a new content key.
+
+  sync                         Push changes committed while the remote was unreachable and show how many are pending.
+
+  history <key> [--show]       List previous passwords of the key with the time they were replaced.
+
+  rollback <key> [n]           Restore the n-th previous password of the key (the latest one by default).
+
+  log                          List vault revisions: date, author and message.
+
+  show [--at <rev>] <key>      Show the key at the given revision of the vault.
+
+  diff <revA> <revB> [--show]  Show keys added (+), changed (~) or removed (-) between revisions, passwords are printed with --show only.
+
+  otp <key>                    Print the current TOTP code of the key and the seconds it's valid for.
+
+  mv [-f] <src> <dst>          Move an entry or a whole folder to a new key, an existing key is replaced with -f only.
+
+  cp [-f] <src> <dst>          Copy an entry or a whole folder to a new key, an existing key is replaced with -f only.
+
+  rename [-f] <key> <name>     Rename an entry or a folder in place.
+
+  find <pattern>               Print keys matching the pattern by the key path, username or URL, never the secrets:
+    --glob                     match the whole key with a glob, * doesn't cross '/', ** does;
+    --regex                    match with a regular expression;
+    --fuzzy                    match characters in order, the best matches first.
+
+  strength [password]          Estimate the password strength in bits of entropy, the password is asked without echo if not given:
+    --readable|--safe|--insane print the theoretical entropy of generated passwords of the level instead.
+
+  audit [--days <n>] [--json]  Report reused, weak, breached, stale (unchanged for more than n days, 365 by default) and empty entries:
+    --breaches <file>          look passwords up in a local Have I Been Pwned SHA-1 list, a file or a directory of ranges.
+
+  import pass <dir> --key <file>  Import a pass store decrypted with the OpenPGP private key, as a single revision.
+
+  import keepass <file.kdbx> [--key-file <file>]  Import a KeePass KDBX 3.1 or 4 database, as a single revision.
+
+  import csv <file.csv>        Import a Chrome, Firefox, Bitwarden or 1Password CSV export keyed by host/username:
+    --format <name>            set the format instead of detecting it by the header;
+    --columns value=column     map columns of any other CSV, values are name, url, username, password, notes and otp.
+  All importers take:
+    --prefix <key>             put imported entries under the key;
+    --conflict <policy>        fail (default), skip, replace or rename existing and repeated keys, -f is replace;
+    --dry-run                  print the keys which would be created without storing them.
+
+  export                       Export the vault to stdout or a file:
+    --format <json|csv|pass>   the output format, json by default;
+    -o, --output <path>        the output file, or the directory of a pass store;
+    --encrypt                  encrypt the json or csv export with a passphrase (armored OpenPGP);
+    --recipient <key file>     the OpenPGP public key a pass store is encrypted to.
 
 Flags:
   -a, --add                Add a new password associated with a specified key. The key separator is '/', allowing for hierarchical key structures (supports pass level key to generate the password automatically).
   
   --pass                   Specify the password to be added (requires -a flag).
+
+  --username, --url        Set the username or the URL of the entry (requires -a flag).
+
+  --notes                  Set free-form notes of the entry (requires -a flag).
+
+  --otp <uri|secret>       Set the TOTP secret of the entry: an otpauth://totp/ URI or a base32 secret (requires -a flag).
+
+  --field name=value       Set a custom field, an empty value removes it (requires -a flag, can be repeated).
+
+  --secret-field name=value
+                           Set a custom field exported as a protected value (requires -a flag, can be repeated).
   
   -p, --get-pass           Retrieve and display the password associated with the specified key.
 
+  --only <field>           Print a single field: pass, username, url, notes, otp or a custom field name (requires -p flag).
+
+  --clip                   Copy the password (or the --only field) to the clipboard instead of printing it (requires -p flag).
+
+  --clip-timeout <dur>     Clear the clipboard after the timeout if it still holds the copied value, 45s by default, 0 keeps it (requires --clip flag).
+
   -d, --delete             Remove key or folder.
   
   -k, --show-keys          List all keys for existing passwords, allowing you to see available entries in the password manager.
   
-  --show-all               Display all existing keys and their associated passwords (requires -k flag).
+  --show-all               Display all existing keys with their passwords, notes and fields in clear (requires -k flag).
 
   -c, --compose            Generate a new password based on specified criteria, defaulting to a safe level of complexity.
   
@@ -36,9 +114,31 @@ Flags:
   
   --insane                 Compose a highly complex password that maximizes security but may be difficult to remember (can be used with -c or -a).
 
+  --length <n|min-max>     Generate a password of the length or a random length in the range, 20 by default (can be used with -c or -a).
+
+  --charset <set>          Allowed characters: comma separated classes (lower, upper, digit, symbol) or literal characters, all classes by default (can be used with -c or -a).
+
+  --require <classes>      Required classes with minimum counts, e.g. upper,digit:2; one character of each class of the charset by default (can be used with -c or -a).
+
+  --forbid <chars>         Characters which can't be used, e.g. symbols rejected by a site (can be used with -c or -a).
+
+  --no-ambiguous           Don't use characters which look alike, like 0 and O or 1 and l (can be used with -c or -a).
+
+  --words <n>              Generate a diceware passphrase of n words from the EFF large wordlist and print its entropy (can be used with -c or -a).
+
+  --wordlist <path>        Pick passphrase words from the file, one per line (can be used with -c or -a).
+
+  --separator <sep>        Passphrase words separator, '-' by default (requires --words flag).
+
+  --capitalize <mode>      Capitalize passphrase words: first (letter), all or random (requires --words flag).
+
+  --digit                  Append a random digit to a random passphrase word (requires --words flag).
+
   -i, --interactive        Launch the Passy application in interactive mode for a guided password management experience [not implemented yet].
   
   --keygen                 Generate a private encryption key and save it to the specified file path for secure password storage.
+
+  --init-passphrase        Derive the vault key from a master passphrase instead of the key file (re-encrypts an existing vault).
   
   -h, --help               Display this help message with available commands and their descriptions.
 `
@@ -54,10 +154,16 @@ func NewCommand() *cobra.Command {
 		deletePass        string
 		thePass           string
 		keyGen            string
+		initPassphrase    bool
 		composePass       bool
 		passLevelReadable bool
 		passLevelSafe     bool
 		passLevelInsane   bool
+		onlyField         string
+		fields            entryFields
+		clip              clipOptions
+		policy            policyFlags
+		passphrase        passphraseFlags
 	)
 
 	cmd := &cobra.Command{
@@ -70,7 +176,20 @@ func NewCommand() *cobra.Command {
 		},
 	}
 
-	cmd.SetHelpTemplate(helpString())
+	// the custom help is for the root command only, subcommands use the default one
+	defaultHelp := cmd.HelpFunc()
+	cmd.SetHelpFunc(func(c *cobra.Command, args []string) {
+		if c != cmd {
+			defaultHelp(c, args)
+			return
+		}
+		fmt.Fprint(c.OutOrStdout(), helpString())
+	})
+
+	cmd.AddCommand(newRotateKeyCommand(), newRecipientsCommand(), newSyncCommand(), newHistoryCommand(), newRollbackCommand(),
+		newLogCommand(), newShowCommand(), newDiffCommand(), newOTPCommand(),
+		newMoveCommand(), newCopyCommand(), newRenameCommand(), newFindCommand(),
+		newStrengthCommand(), newAuditCommand(), newImportCommand(), newExportCommand(), newClipClearCommand())
 
 	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "run Passy in interactive mode [not implemented yet]")
 	cmd.Flags().BoolVarP(&showKeys, "show-keys", "k", false, "show keys for all existing passwords")
@@ -79,27 +198,38 @@ func NewCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&addPass, "add", "a", "", "add password by key, key separator is '/' (supports pass level key to generate the pass automatically)")
 	cmd.Flags().StringVarP(&deletePass, "delete", "d", "", "delete key or key folder, key separator is '/'")
 	cmd.Flags().StringVar(&thePass, "pass", "", "[-a] set password")
+	cmd.Flags().StringVar(&fields.username, "username", "", "[-a] set username")
+	cmd.Flags().StringVar(&fields.url, "url", "", "[-a] set URL")
+	cmd.Flags().StringVar(&fields.notes, "notes", "", "[-a] set notes")
+	cmd.Flags().StringVar(&fields.otp, "otp", "", "[-a] set TOTP secret: otpauth://totp/ URI or base32 secret")
+	cmd.Flags().StringArrayVar(&fields.custom, "field", nil, "[-a] set custom field name=value, empty value removes the field")
+	cmd.Flags().StringArrayVar(&fields.secret, "secret-field", nil, "[-a] set secret custom field name=value, it's exported as a protected value")
+	cmd.Flags().StringVar(&onlyField, "only", "", "[-p] print the value of a single field: pass, username, url, notes, otp or a custom field name")
+	clip.addFlags(cmd, "[-p] ")
 	cmd.Flags().StringVar(&keyGen, "keygen", "", "generate the private encryption key on given path")
+	cmd.Flags().BoolVar(&initPassphrase, "init-passphrase", false, "derive the vault key from a master passphrase")
 	cmd.Flags().BoolVarP(&composePass, "compose", "c", false, "compose password (safe level by default)")
 	cmd.Flags().BoolVar(&passLevelReadable, "readable", false, "[-c|-a] compose password that is readable, easy to remember and pretty safe")
 	cmd.Flags().BoolVar(&passLevelSafe, "safe", false, "[-c|-a] compose password that is safe and have chances to be remembered")
 	cmd.Flags().BoolVar(&passLevelInsane, "insane", false, "[-c|-a] compose password that is insanly complex")
+	policy.addFlags(cmd)
+	passphrase.addFlags(cmd)
 
 	// Parse the command
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		return executeCommand(interactive, showKeys, showAll, getPass, addPass, deletePass, thePass, keyGen, composePass, passLevelReadable, passLevelSafe, passLevelInsane)
+		return executeCommand(interactive, showKeys, showAll, getPass, addPass, deletePass, thePass, keyGen, initPassphrase, composePass, passLevelReadable, passLevelSafe, passLevelInsane, onlyField, fields, clip, policy, passphrase)
 	}
 
 	return cmd
 }
 
-func executeCommand(interactive, showKeys, showAll bool, getPass, addPass, deletePass, thePass, keyGen string, composePass, passLevelReadable, passLevelSafe, passLevelInsane bool) error {
+func executeCommand(interactive, showKeys, showAll bool, getPass, addPass, deletePass, thePass, keyGen string, initPassphrase, composePass, passLevelReadable, passLevelSafe, passLevelInsane bool, onlyField string, fields entryFields, clip clipOptions, policy policyFlags, passphrase passphraseFlags) error {
 	if interactive {
 		return fmt.Errorf("interactive mode is not implemented")
 	}
 
 	if composePass {
-		return handlePasswordComposition(passLevelReadable, passLevelSafe, passLevelInsane)
+		return handlePasswordComposition(policy, passphrase, passLevelReadable, passLevelSafe, passLevelInsane)
 	}
 
 	if showKeys {
@@ -107,11 +237,11 @@ func executeCommand(interactive, showKeys, showAll bool, getPass, addPass, delet
 	}
 
 	if getPass != "" {
-		return handleGetPass(getPass)
+		return handleGetPass(getPass, onlyField, clip)
 	}
 
 	if addPass != "" {
-		return handleAddPassword(addPass, thePass, passLevelReadable, passLevelSafe, passLevelInsane)
+		return handleAddPassword(addPass, thePass, fields, policy, passphrase, passLevelReadable, passLevelSafe, passLevelInsane)
 	}
 
 	if deletePass != "" {
@@ -122,25 +252,38 @@ func executeCommand(interactive, showKeys, showAll bool, getPass, addPass, delet
 		return handleKeyGeneration(keyGen)
 	}
 
+	if initPassphrase {
+		return handleInitPassphrase()
+	}
+
 	return fmt.Errorf("no valid command provided")
 }
 
-func handlePasswordComposition(passLevelReadable, passLevelSafe, passLevelInsane bool) error {
+func handlePasswordComposition(policy policyFlags, passphrase passphraseFlags, passLevelReadable, passLevelSafe, passLevelInsane bool) error {
 	gen, err := passgen.New()
 	if err != nil {
 		return fmt.Errorf("unable to create generator: %v", err)
 	}
 
+	var pass string
 	switch {
+	case passphrase.set():
+		pass, err = generatePassphrase(gen, passphrase)
+	case policy.set():
+		pass, err = generateWithPolicy(gen, policy)
 	case passLevelReadable:
-		fmt.Println(gen.GenReadablePass())
+		pass, err = generateLevel(gen.GenReadablePass, passgen.ReadableEntropy())
 	case passLevelSafe:
-		fmt.Println(gen.GenSafePass())
+		pass, err = generateLevel(gen.GenSafePass, passgen.SafeEntropy())
 	case passLevelInsane:
-		fmt.Println(gen.GenInsanePass())
+		pass, err = generateLevel(gen.GenInsanePass, passgen.InsaneEntropy())
 	default:
-		fmt.Println(gen.GenSafePass())
+		pass, err = generateLevel(gen.GenSafePass, passgen.SafeEntropy())
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to generate password")
 	}
+	fmt.Println(pass)
 	return nil
 }
 
@@ -157,7 +300,7 @@ func handleShowKeys(showAll bool) error {
 	return nil
 }
 
-func handleGetPass(key string) error {
+func handleGetPass(key, onlyField string, clip clipOptions) error {
 	flds, err := folders()
 	if err != nil {
 		return err
@@ -165,34 +308,59 @@ func handleGetPass(key string) error {
 
 	sf, found := flds.GetSubFolder(key)
 	if !found {
-		fmt.Println("no such key")
+		return fmt.Errorf("no such key: %s", key)
+	}
+
+	if clip.enabled {
+		if onlyField == "" {
+			onlyField = storage.FieldPass
+		}
+		value, ok := sf.Field(onlyField)
+		if !ok {
+			return fmt.Errorf("%q has no field %q", key, onlyField)
+		}
+		return copyToClipboard(value, clip)
+	}
+
+	if onlyField != "" {
+		value, ok := sf.Field(onlyField)
+		if !ok {
+			return fmt.Errorf("%q has no field %q", key, onlyField)
+		}
+		fmt.Println(value)
+		return nil
 	}
 	fmt.Println(sf.String("")())
 	return nil
 }
 
-func handleAddPassword(addPass, thePass string, passLevelReadable, passLevelSafe, passLevelInsane bool) error {
+func handleAddPassword(addPass, thePass string, fields entryFields, policy policyFlags, passphrase passphraseFlags, passLevelReadable, passLevelSafe, passLevelInsane bool) error {
 	gen, err := passgen.New()
 	if err != nil {
 		return fmt.Errorf("unable to create generator: %v", err)
 	}
 
-	var pass string
-	switch {
-	case passLevelReadable:
-		pass = gen.GenReadablePass()
-	case passLevelSafe:
-		pass = gen.GenSafePass()
-	case passLevelInsane:
-		pass = gen.GenInsanePass()
-	default:
-		return fmt.Errorf("please set the password strength option or [--pass] flag")
-	}
-
-	if thePass != "" {
-		pass = thePass
+	pass := thePass
+	if pass == "" {
+		switch {
+		case passphrase.set():
+			pass, err = generatePassphrase(gen, passphrase)
+		case policy.set():
+			pass, err = generateWithPolicy(gen, policy)
+		case passLevelReadable:
+			pass, err = generateLevel(gen.GenReadablePass, passgen.ReadableEntropy())
+		case passLevelSafe:
+			pass, err = generateLevel(gen.GenSafePass, passgen.SafeEntropy())
+		case passLevelInsane:
+			pass, err = generateLevel(gen.GenInsanePass, passgen.InsaneEntropy())
+		case fields.empty():
+			return fmt.Errorf("please set the password strength option or [--pass] flag")
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to generate password")
+		}
 	}
-	return savePass(addPass, pass)
+	return saveEntry(addPass, pass, thePass != "", fields)
 }
 
 func handleDeletePassword(key string) error {
@@ -201,14 +369,9 @@ func handleDeletePassword(key string) error {
 	fmt.Scanln(&ans)
 
 	if ans == "y" || ans == "Y" || ans == "yes" {
-		cfg, err := storage.ParseConfig()
-		if err != nil {
-			return errors.Wrap(err, "failed to parse config")
-		}
-
-		st, err := storage.New(cfg)
+		st, err := openStorage()
 		if err != nil {
-			return errors.Wrap(err, "failed to init storage")
+			return err
 		}
 
 		flds, err := st.Decrypt()
@@ -223,9 +386,11 @@ func handleDeletePassword(key string) error {
 			return errors.Wrap(err, "failed to encrypt new password")
 		}
 
-		if err = st.Store(nil); err != nil {
+		if err = storeVault(st, nil); err != nil {
 			return errors.Wrap(err, "failed to store new password")
 		}
+		fmt.Printf("%q was deleted\n", key)
+		return nil
 	}
 
 	fmt.Println("ok, leave everything as is")
@@ -246,33 +411,127 @@ func handleKeyGeneration(keyGen string) error {
 	return nil
 }
 
-func folders() (*storage.Folder, error) {
+func handleInitPassphrase() error {
+	cfg, err := storage.LoadConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	// an existing vault encrypted with the key file is re-encrypted with the passphrase
+	migrate := !cfg.UsePassphrase
+	if migrate {
+		if err := cfg.Validate(); err != nil {
+			migrate = false
+		}
+	}
+
+	var st *storage.Storage
+	flds := &storage.Folder{Name: "", SubFolder: []*storage.Folder{}}
+	if migrate {
+		if st, err = storage.New(cfg); err != nil {
+			return errors.Wrap(err, "failed to init storage")
+		}
+		if err = st.Update(); err != nil {
+			return errors.Wrap(err, "failed to update data from the repo")
+		}
+		if err := requireOnline(st); err != nil {
+			return err
+		}
+		if st.Data != "" {
+			if flds, err = st.Decrypt(); err != nil {
+				return errors.Wrap(err, "failed to decrypt")
+			}
+		}
+	}
+
+	pass, err := readNewPassphrase()
+	if err != nil {
+		return err
+	}
+
+	if st == nil {
+		cfg.UsePassphrase = true
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		if st, err = storage.NewWithPassphrase(cfg, pass); err != nil {
+			return errors.Wrap(err, "failed to init storage")
+		}
+		if err = st.Update(); err != nil {
+			return errors.Wrap(err, "failed to update data from the repo")
+		}
+		if st.Data != "" {
+			return errors.New("the vault is already initialized, check UsePassphrase in the config")
+		}
+	}
+	if err := st.SetPassphrase(pass); err != nil {
+		return err
+	}
+
+	if err := st.Encrypt(flds); err != nil {
+		return errors.Wrap(err, "failed to encrypt")
+	}
+	msg := "switch vault to passphrase key"
+	if err := storeVault(st, &msg); err != nil {
+		return errors.Wrap(err, "failed to store the vault")
+	}
+
+	cfg.UsePassphrase = true
+	cfg.IdentityPath = ""
+	if err := cfg.Save(); err != nil {
+		return errors.Wrap(err, "failed to update config")
+	}
+	fmt.Println("the vault is now unlocked with the master passphrase")
+	return nil
+}
+
+// openStorage parses the config and initializes the storage, asking for the passphrase if needed.
+func openStorage() (*storage.Storage, error) {
 	cfg, err := storage.ParseConfig()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse config")
 	}
 
-	st, err := storage.New(cfg)
+	var st *storage.Storage
+	if cfg.UsePassphrase {
+		pass, err := readPassphrase("master passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		st, err = storage.NewWithPassphrase(cfg, pass)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to init storage")
+		}
+	} else {
+		st, err = storage.New(cfg)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to init storage")
+		}
+	}
+	st.Resolve = resolveConflict
+	return st, nil
+}
+
+func folders() (*storage.Folder, error) {
+	st, err := openStorage()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to init storage")
+		return nil, err
 	}
 
 	folders, err := st.Decrypt()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to decrypt")
 	}
+	warnOffline(st)
 	return folders, nil
 }
 
-func savePass(key, pass string) error {
-	cfg, err := storage.ParseConfig()
-	if err != nil {
-		return errors.Wrap(err, "failed to parse config")
-	}
-
-	st, err := storage.New(cfg)
+// saveEntry sets the password and the fields of the key, the password is kept if it's empty.
+// The strength of passwords given by the user is checked.
+func saveEntry(key, pass string, userPass bool, fields entryFields) error {
+	st, err := openStorage()
 	if err != nil {
-		return errors.Wrap(err, "failed to init storage")
+		return err
 	}
 
 	flds, err := st.Decrypt()
@@ -280,18 +539,35 @@ func savePass(key, pass string) error {
 		return errors.Wrap(err, "failed to decrypt")
 	}
 
-	if err = flds.Add(key, pass); err != nil {
+	if pass != "" {
+		if err = flds.Add(key, pass); err != nil {
+			return errors.Wrap(err, "failed to add a new key")
+		}
+	}
+	entry, err := flds.Create(key)
+	if err != nil {
 		return errors.Wrap(err, "failed to add a new key")
 	}
+	if err = fields.apply(entry); err != nil {
+		return err
+	}
 
 	if err = st.Encrypt(flds); err != nil {
 		return errors.Wrap(err, "failed to encrypt")
 	}
 
-	if err = st.Store(nil); err != nil {
+	if err = storeVault(st, nil); err != nil {
 		return errors.Wrap(err, "failed to store new password")
 	}
 
+	if pass == "" {
+		fmt.Printf("the entry %q was updated successfully\n", key)
+		return nil
+	}
+	if userPass {
+		warnWeakPassword(st.Cfg, pass)
+		warnBreachedPassword(st.Cfg, pass)
+	}
 	fmt.Printf("the password %q was added successfully\n", pass)
 	return nil
 }
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koss-null/passy/internal/otp"
+	"github.com/koss-null/passy/internal/storage"
+)
+
+// entryFields are the entry fields set with -a, empty values are left as is.
+type entryFields struct {
+	username string
+	url      string
+	notes    string
+	// otp is an otpauth:// URI or a base32 TOTP secret
+	otp string
+	// custom and secret are name=value pairs
+	custom []string
+	secret []string
+}
+
+func (e entryFields) empty() bool {
+	return e.username == "" && e.url == "" && e.notes == "" && e.otp == "" && len(e.custom) == 0 && len(e.secret) == 0
+}
+
+// apply sets the fields to the entry.
+func (e entryFields) apply(entry *storage.Folder) error {
+	if e.username != "" {
+		entry.Username = e.username
+	}
+	if e.url != "" {
+		entry.URL = e.url
+	}
+	if e.notes != "" {
+		entry.Notes = e.notes
+	}
+	if e.otp != "" {
+		totp, err := otp.Parse(e.otp)
+		if err != nil {
+			return err
+		}
+		entry.OTP = totp.URI()
+	}
+
+	for _, pairs := range []struct {
+		values []string
+		secret bool
+	}{{e.custom, false}, {e.secret, true}} {
+		for _, pair := range pairs.values {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid field %q, expected name=value", pair)
+			}
+			if err := entry.SetField(strings.TrimSpace(name), value, pairs.secret); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/exporter"
+)
+
+const exportFilePerm = 0o600
+
+func newExportCommand() *cobra.Command {
+	var (
+		format    string
+		output    string
+		encrypt   bool
+		recipient string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the vault as JSON, CSV or a pass store",
+		Long: `JSON keeps everything including the password history, CSV has a column per custom field.
+Both are plain text unless --encrypt asks for a passphrase and writes an armored OpenPGP message,
+which gpg --decrypt reads. The pass format writes a password-store directory encrypted to --recipient.`,
+		Example: `  passy export --encrypt -o vault.json.asc
+  passy export --format csv -o vault.csv
+  passy export --format pass --recipient key.asc -o ~/.password-store`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleExport(format, output, recipient, encrypt)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", exporter.FormatJSON, "export format: "+strings.Join(exporter.Formats, ", "))
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output file or pass store directory, stdout if not set")
+	cmd.Flags().BoolVar(&encrypt, "encrypt", false, "encrypt the export to a passphrase")
+	cmd.Flags().StringVar(&recipient, "recipient", "", "[pass] OpenPGP public key to encrypt the store to")
+
+	return cmd
+}
+
+func handleExport(format, output, recipient string, encrypt bool) error {
+	if !slices.Contains(exporter.Formats, format) {
+		return fmt.Errorf("unknown format %q, use one of %s", format, strings.Join(exporter.Formats, ", "))
+	}
+	if format == exporter.FormatPass {
+		if output == "" || recipient == "" {
+			return errors.New("the pass format needs --output and --recipient")
+		}
+		if encrypt {
+			return errors.New("the pass store is encrypted to --recipient, --encrypt is for json and csv")
+		}
+	} else if recipient != "" {
+		return errors.New("--recipient is for the pass format")
+	}
+
+	flds, err := folders()
+	if err != nil {
+		return err
+	}
+	entries := exporter.Entries(flds)
+
+	if format == exporter.FormatPass {
+		recipients, err := exporter.ReadRecipients(recipient)
+		if err != nil {
+			return err
+		}
+		skipped, err := exporter.WritePassStore(output, entries, recipients)
+		if err != nil {
+			return err
+		}
+		for _, key := range skipped {
+			fmt.Fprintf(os.Stderr, "skipped %s: the key can't be a file path\n", key)
+		}
+		fmt.Fprintf(os.Stderr, "%d entries exported to %s\n", len(entries)-len(skipped), output)
+		return nil
+	}
+
+	var passphrase []byte
+	if encrypt {
+		if passphrase, err = readExportPassphrase(); err != nil {
+			return err
+		}
+	}
+
+	out := os.Stdout
+	if output != "" {
+		if out, err = os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, exportFilePerm); err != nil {
+			return errors.Wrap(err, "failed to create the export file")
+		}
+		defer out.Close()
+	}
+
+	var w io.Writer = out
+
+	var enc io.WriteCloser
+	if encrypt {
+		if enc, err = exporter.Encrypt(w, passphrase); err != nil {
+			return errors.Wrap(err, "failed to encrypt the export")
+		}
+		w = enc
+	}
+
+	if format == exporter.FormatCSV {
+		err = exporter.WriteCSV(w, entries)
+	} else {
+		err = exporter.WriteJSON(w, entries)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to write the export")
+	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return errors.Wrap(err, "failed to encrypt the export")
+		}
+	}
+	if output != "" {
+		if err := out.Close(); err != nil {
+			return errors.Wrap(err, "failed to write the export")
+		}
+	}
+
+	if !encrypt {
+		fmt.Fprintln(os.Stderr, "warning: the export holds passwords in plain text")
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "%d entries exported to %s\n", len(entries), output)
+	}
+	return nil
+}
+
+// readExportPassphrase asks for the passphrase of an encrypted export twice.
+func readExportPassphrase() ([]byte, error) {
+	pass, err := readSecret("export passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	confirm, err := readSecret("repeat export passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if string(pass) != string(confirm) {
+		return nil, fmt.Errorf("passphrases do not match")
+	}
+	if len(pass) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	return pass, nil
+}
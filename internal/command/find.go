@@ -0,0 +1,58 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/storage"
+)
+
+func newFindCommand() *cobra.Command {
+	var glob, regex, fuzzy bool
+
+	cmd := &cobra.Command{
+		Use:   "find <pattern>",
+		Short: "Find keys matching the pattern by the key path, username or URL",
+		Long: `Prints matching keys only, never the secrets. The pattern is a case-insensitive substring by default.
+Globs are case-insensitive too: * doesn't cross the '/' separator, ** does.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode := storage.MatchSubstring
+			switch {
+			case glob:
+				mode = storage.MatchGlob
+			case regex:
+				mode = storage.MatchRegex
+			case fuzzy:
+				mode = storage.MatchFuzzy
+			}
+			return handleFind(args[0], mode)
+		},
+	}
+	cmd.Flags().BoolVar(&glob, "glob", false, "match the whole key with a glob pattern")
+	cmd.Flags().BoolVar(&regex, "regex", false, "match with a regular expression")
+	cmd.Flags().BoolVar(&fuzzy, "fuzzy", false, "match characters in order, the best matches first")
+	cmd.MarkFlagsMutuallyExclusive("glob", "regex", "fuzzy")
+
+	return cmd
+}
+
+func handleFind(pattern, mode string) error {
+	flds, err := folders()
+	if err != nil {
+		return err
+	}
+
+	keys, err := flds.Find(pattern, mode)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys match %q", pattern)
+	}
+	for _, key := range keys {
+		fmt.Println(key)
+	}
+	return nil
+}
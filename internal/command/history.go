@@ -0,0 +1,91 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newHistoryCommand() *cobra.Command {
+	var show bool
+
+	cmd := &cobra.Command{
+		Use:   "history <key>",
+		Short: "List previous passwords of the key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleHistory(args[0], show)
+		},
+	}
+	cmd.Flags().BoolVar(&show, "show", false, "show previous passwords")
+
+	return cmd
+}
+
+func newRollbackCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollback <key> [n]",
+		Short: "Restore the n-th previous password of the key (the latest one by default)",
+		Long: `Restores a password listed by passy history. The current password goes to the history,
+so the rollback can be undone with another rollback.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n := 1
+			if len(args) == 2 {
+				var err error
+				if n, err = strconv.Atoi(args[1]); err != nil {
+					return fmt.Errorf("invalid history number %q", args[1])
+				}
+			}
+			return handleRollback(args[0], n)
+		},
+	}
+}
+
+func handleHistory(key string, show bool) error {
+	flds, err := folders()
+	if err != nil {
+		return err
+	}
+
+	entry, found := flds.GetSubFolder(key)
+	if !found {
+		return fmt.Errorf("no such key: %s", key)
+	}
+	if len(entry.History) == 0 {
+		fmt.Printf("%q has no previous passwords\n", key)
+		return nil
+	}
+
+	for i, record := range entry.History {
+		pass := "🔒"
+		if show {
+			pass = record.Pass
+		}
+		fmt.Printf("%d\t%s\t%s\n", i+1, record.Time.Local().Format("2006-01-02 15:04:05"), pass)
+	}
+	return nil
+}
+
+func handleRollback(key string, n int) error {
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+
+	flds, err := st.Decrypt()
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt")
+	}
+	if err := flds.Rollback(key, n); err != nil {
+		return err
+	}
+
+	if err := encryptAndStore(st, flds, fmt.Sprintf("roll back %s", key)); err != nil {
+		return err
+	}
+	fmt.Printf("the password of %q was rolled back\n", key)
+	return nil
+}
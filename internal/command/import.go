@@ -0,0 +1,255 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/importer"
+	"github.com/koss-null/passy/internal/kdbx"
+	"github.com/koss-null/passy/internal/storage"
+)
+
+// importOptions are the flags shared by all importers.
+type importOptions struct {
+	prefix   string
+	force    bool
+	conflict string
+	dryRun   bool
+}
+
+func (o *importOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.prefix, "prefix", "", "put imported entries under this key")
+	cmd.Flags().StringVar(&o.conflict, "conflict", importer.ConflictFail,
+		"what to do with existing or repeated keys: "+strings.Join(importer.Conflicts, ", "))
+	cmd.Flags().BoolVarP(&o.force, "force", "f", false, "replace existing keys, the same as --conflict replace")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "print the keys which would be created without storing them")
+}
+
+func (o importOptions) validate() error {
+	if !slices.Contains(importer.Conflicts, o.conflict) {
+		return fmt.Errorf("unknown --conflict %q, use one of %s", o.conflict, strings.Join(importer.Conflicts, ", "))
+	}
+	return nil
+}
+
+func newImportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import entries from other password managers",
+		Long:  `All imported entries are stored as a single revision of the vault.`,
+	}
+
+	var (
+		opts    importOptions
+		keyPath string
+	)
+	passCmd := &cobra.Command{
+		Use:   "pass <dir>",
+		Short: "Import a pass (password-store) directory",
+		Long: `Every .gpg file becomes an entry keyed by its path in the store, the first line is the password
+and the rest are notes. The files are decrypted with the OpenPGP private key exported by
+gpg --export-secret-keys, its passphrase is asked if the key is encrypted.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleImportPass(args[0], keyPath, opts)
+		},
+	}
+	passCmd.Flags().StringVar(&keyPath, "key", "", "OpenPGP private key of the store, armored or binary")
+	_ = passCmd.MarkFlagRequired("key")
+	opts.addFlags(passCmd)
+
+	var (
+		keePassOpts importOptions
+		keyFile     string
+	)
+	keePassCmd := &cobra.Command{
+		Use:   "keepass <file.kdbx>",
+		Short: "Import a KeePass KDBX 3.1 or 4 database",
+		Long: `Groups become folders and entries become keys named by their titles, with the username, URL,
+notes and custom strings. The recycle bin, attachments and previous versions of entries are not imported
+and are reported. The master password is asked, leave it empty to open the database with the key file only.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleImportKeePass(args[0], keyFile, keePassOpts)
+		},
+	}
+	keePassCmd.Flags().StringVar(&keyFile, "key-file", "", "key file of the database")
+	keePassOpts.addFlags(keePassCmd)
+
+	var (
+		csvOpts    importOptions
+		csvFormat  string
+		csvColumns map[string]string
+	)
+	csvCmd := &cobra.Command{
+		Use:   "csv <file.csv>",
+		Short: "Import a CSV export of Chrome, Firefox, Bitwarden, 1Password or any other CSV",
+		Long: `Every row becomes an entry keyed by the site host and the username, e.g. github.com/alice,
+rows without a URL are keyed by their name. The format is detected by the header unless --format is set.
+Other CSV files are read with --format generic and --columns mapping entry values to column names
+or numbers, the rest of the columns become custom fields.`,
+		Example: `  passy import csv "Chrome Passwords.csv" --dry-run
+  passy import csv export.csv --format generic --columns url=Website,username=Login,password=3`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleImportCSV(args[0], importer.CSVOptions{Format: csvFormat, Columns: csvColumns}, csvOpts)
+		},
+	}
+	csvCmd.Flags().StringVar(&csvFormat, "format", "", "CSV format: "+strings.Join(importer.CSVFormats, ", "))
+	csvCmd.Flags().StringToStringVar(&csvColumns, "columns", nil,
+		"columns of the generic format, value=column for values "+strings.Join(importer.CSVColumns, ", "))
+	csvOpts.addFlags(csvCmd)
+
+	cmd.AddCommand(passCmd, keePassCmd, csvCmd)
+	return cmd
+}
+
+func handleImportPass(dir, keyPath string, opts importOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	keyring, err := importer.ReadKeyRing(keyPath, func() ([]byte, error) {
+		return readSecret("OpenPGP key passphrase: ")
+	})
+	if err != nil {
+		return err
+	}
+	res, err := importer.ReadPassStore(dir, keyring)
+	if err != nil {
+		return err
+	}
+	return importEntries(res, "import pass store "+dir, opts)
+}
+
+func handleImportKeePass(path, keyFile string, opts importOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the database")
+	}
+	defer f.Close()
+
+	var creds kdbx.Credentials
+	if keyFile != "" {
+		if creds.KeyFile, err = os.ReadFile(keyFile); err != nil {
+			return errors.Wrap(err, "failed to read the key file")
+		}
+	}
+	pass, err := readSecret("KeePass master password: ")
+	if err != nil {
+		return err
+	}
+	if len(pass) > 0 || keyFile == "" {
+		// an empty password is a valid one unless the key file is used alone
+		creds.Password = append([]byte{}, pass...)
+	}
+
+	res, err := importer.ReadKeePass(f, creds)
+	if err != nil {
+		return err
+	}
+	return importEntries(res, "import KeePass database "+filepath.Base(path), opts)
+}
+
+func handleImportCSV(path string, csvOpts importer.CSVOptions, opts importOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if len(csvOpts.Columns) > 0 && csvOpts.Format == "" {
+		csvOpts.Format = importer.FormatGeneric
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the CSV file")
+	}
+	defer f.Close()
+
+	res, err := importer.ReadCSV(f, csvOpts)
+	if err != nil {
+		return err
+	}
+	return importEntries(res, "import CSV "+filepath.Base(path), opts)
+}
+
+// importEntries adds the imported entries to the vault as a single revision and reports what was skipped.
+func importEntries(res *importer.Result, msg string, opts importOptions) error {
+	for _, s := range res.Skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s\n", s)
+	}
+	if len(res.Entries) == 0 {
+		return errors.New("nothing to import")
+	}
+
+	if prefix := strings.Trim(opts.prefix, "/"); prefix != "" {
+		for i := range res.Entries {
+			res.Entries[i].Key = path.Join(prefix, res.Entries[i].Key)
+		}
+	}
+	conflict := opts.conflict
+	if opts.force {
+		conflict = importer.ConflictReplace
+	}
+
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+	flds, err := st.Decrypt()
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt")
+	}
+	applied, err := importer.Apply(flds, res.Entries, conflict)
+	if err != nil {
+		if errors.Is(err, storage.ErrKeyExists) {
+			return errors.Wrap(err, "use --conflict to skip, replace or rename them, or --prefix to import into a folder")
+		}
+		return err
+	}
+	printApplied(applied)
+	if len(applied.Stored) == 0 {
+		return errors.New("nothing to import")
+	}
+
+	if opts.dryRun {
+		tree := &storage.Folder{Name: "", SubFolder: []*storage.Folder{}}
+		for _, e := range applied.Stored {
+			if err := tree.Put(e.Key, e.Values, true); err != nil {
+				return err
+			}
+		}
+		fmt.Print(tree.SecureString("")())
+		fmt.Printf("%d entries would be imported, nothing was stored\n", len(applied.Stored))
+		return nil
+	}
+
+	if err := encryptAndStore(st, flds, msg); err != nil {
+		return err
+	}
+	fmt.Printf("%d entries imported\n", len(applied.Stored))
+	return nil
+}
+
+func printApplied(applied *importer.Applied) {
+	for _, key := range applied.Duplicates {
+		fmt.Fprintf(os.Stderr, "skipped %s: a duplicate\n", key)
+	}
+	for _, key := range applied.Skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s: the key exists\n", key)
+	}
+	for _, key := range applied.Replaced {
+		fmt.Printf("replaced %s\n", key)
+	}
+	for _, r := range applied.Renamed {
+		fmt.Printf("renamed %s -> %s\n", r[0], r[1])
+	}
+}
@@ -0,0 +1,89 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/storage"
+)
+
+func newMoveCommand() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "mv <src> <dst>",
+		Short: "Move an entry or a folder to a new key",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleTransfer(args[0], args[1], fmt.Sprintf("move %s to %s", args[0], args[1]), func(flds *storage.Folder) error {
+				return flds.Move(args[0], args[1], force)
+			})
+		},
+	}
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "replace the existing destination key")
+
+	return cmd
+}
+
+func newCopyCommand() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "cp <src> <dst>",
+		Short: "Copy an entry or a folder to a new key",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleTransfer(args[0], args[1], fmt.Sprintf("copy %s to %s", args[0], args[1]), func(flds *storage.Folder) error {
+				return flds.Copy(args[0], args[1], force)
+			})
+		},
+	}
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "replace the existing destination key")
+
+	return cmd
+}
+
+func newRenameCommand() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "rename <key> <new-name>",
+		Short: "Rename an entry or a folder in place",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleTransfer(args[0], args[1], fmt.Sprintf("rename %s to %s", args[0], args[1]), func(flds *storage.Folder) error {
+				return flds.Rename(args[0], args[1], force)
+			})
+		},
+	}
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "replace the existing key with the new name")
+
+	return cmd
+}
+
+// handleTransfer applies the move, copy or rename to the vault and stores it as a single revision.
+func handleTransfer(src, dst, msg string, transfer func(flds *storage.Folder) error) error {
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+
+	flds, err := st.Decrypt()
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt")
+	}
+	if err := transfer(flds); err != nil {
+		if errors.Is(err, storage.ErrKeyExists) {
+			return errors.Wrap(err, "use --force to replace it")
+		}
+		return err
+	}
+
+	if err := encryptAndStore(st, flds, msg); err != nil {
+		return err
+	}
+	fmt.Printf("%q -> %q\n", src, dst)
+	return nil
+}
@@ -0,0 +1,59 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/otp"
+)
+
+func newOTPCommand() *cobra.Command {
+	var clip clipOptions
+
+	cmd := &cobra.Command{
+		Use:   "otp <key>",
+		Short: "Print the current TOTP code of the key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleOTP(args[0], clip)
+		},
+	}
+	clip.addFlags(cmd, "")
+
+	return cmd
+}
+
+func handleOTP(key string, clip clipOptions) error {
+	flds, err := folders()
+	if err != nil {
+		return err
+	}
+
+	entry, found := flds.GetSubFolder(key)
+	if !found {
+		return fmt.Errorf("no such key: %s", key)
+	}
+	if entry.OTP == "" {
+		return fmt.Errorf("%q has no TOTP secret, set it with passy -a %s --otp <uri>", key, key)
+	}
+
+	totp, err := otp.Parse(entry.OTP)
+	if err != nil {
+		return errors.Wrap(err, "invalid TOTP secret")
+	}
+	now := time.Now()
+	code, err := totp.Code(now)
+	if err != nil {
+		return err
+	}
+	remaining := int(totp.Remaining(now).Seconds())
+	if clip.enabled {
+		fmt.Printf("the code is valid for %ds\n", remaining)
+		return copyToClipboard(code, clip)
+	}
+	fmt.Printf("%s (%ds left)\n", code, remaining)
+	return nil
+}
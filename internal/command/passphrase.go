@@ -0,0 +1,57 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/passgen"
+)
+
+// passphraseFlags describe a diceware passphrase of -c and -a, see passgen.PassphraseOptions.
+type passphraseFlags struct {
+	words      int
+	wordList   string
+	separator  string
+	capitalize string
+	digit      bool
+}
+
+func (f *passphraseFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&f.words, "words", 0, "[-c|-a] generate a passphrase of the number of words")
+	cmd.Flags().StringVar(&f.wordList, "wordlist", "", "[-c|-a] pick passphrase words from the file instead of the EFF large wordlist")
+	cmd.Flags().StringVar(&f.separator, "separator", "-", "[--words] passphrase words separator")
+	cmd.Flags().StringVar(&f.capitalize, "capitalize", "", "[--words] capitalize words: first (letter), all or random")
+	cmd.Flags().BoolVar(&f.digit, "digit", false, "[--words] append a random digit to a random word")
+}
+
+// set reports if a passphrase is requested.
+func (f passphraseFlags) set() bool {
+	return f.words > 0 || f.wordList != ""
+}
+
+// generatePassphrase generates a passphrase and reports its entropy to stderr, so it doesn't mix with the output.
+func generatePassphrase(gen *passgen.Generator, flags passphraseFlags) (string, error) {
+	opts := passgen.DefaultPassphraseOptions()
+	if flags.words > 0 {
+		opts.Words = flags.words
+	}
+	opts.Separator = flags.separator
+	opts.Capitalize = flags.capitalize
+	opts.Digit = flags.digit
+	if flags.wordList != "" {
+		words, err := passgen.LoadWordList(flags.wordList)
+		if err != nil {
+			return "", err
+		}
+		opts.WordList = words
+	}
+
+	pass, entropy, err := gen.GenPassphrase(opts)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "passphrase entropy: %.1f bits\n", entropy)
+	return pass, nil
+}
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/passgen"
+)
+
+// policyFlags describe the password policy of -c and -a, see passgen.Policy.
+type policyFlags struct {
+	length      string
+	charset     string
+	require     string
+	forbid      string
+	noAmbiguous bool
+}
+
+func (f *policyFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.length, "length", "", "[-c|-a] password length or range, e.g. 16 or 12-20")
+	cmd.Flags().StringVar(&f.charset, "charset", "", "[-c|-a] allowed characters: comma separated classes (lower, upper, digit, symbol) or literal characters")
+	cmd.Flags().StringVar(&f.require, "require", "", "[-c|-a] required character classes with minimum counts, e.g. upper,digit:2,symbol:1")
+	cmd.Flags().StringVar(&f.forbid, "forbid", "", "[-c|-a] characters which can't be used")
+	cmd.Flags().BoolVar(&f.noAmbiguous, "no-ambiguous", false, "[-c|-a] don't use characters which look alike, e.g. 0 and O")
+}
+
+// set reports if any policy flag is set.
+func (f policyFlags) set() bool {
+	return f.length != "" || f.charset != "" || f.require != "" || f.forbid != "" || f.noAmbiguous
+}
+
+func (f policyFlags) policy() (passgen.Policy, error) {
+	p := passgen.DefaultPolicy()
+
+	if f.length != "" {
+		minLen, maxLen, isRange := strings.Cut(f.length, "-")
+		var err error
+		if p.MinLength, err = strconv.Atoi(strings.TrimSpace(minLen)); err != nil {
+			return p, fmt.Errorf("invalid length %q", f.length)
+		}
+		p.MaxLength = p.MinLength
+		if isRange {
+			if p.MaxLength, err = strconv.Atoi(strings.TrimSpace(maxLen)); err != nil {
+				return p, fmt.Errorf("invalid length %q", f.length)
+			}
+		}
+	}
+
+	if f.charset != "" {
+		p.Charset = nil
+		for _, item := range strings.Split(f.charset, ",") {
+			if chars, err := passgen.ClassCharset(strings.TrimSpace(item)); err == nil {
+				p.Charset = append(p.Charset, chars...)
+			} else {
+				p.Charset = append(p.Charset, []rune(item)...)
+			}
+		}
+	}
+
+	if f.require != "" {
+		p.Require = make(map[string]int)
+		for _, item := range strings.Split(f.require, ",") {
+			class, count, hasCount := strings.Cut(strings.TrimSpace(item), ":")
+			n := 1
+			if hasCount {
+				var err error
+				if n, err = strconv.Atoi(count); err != nil {
+					return p, fmt.Errorf("invalid required count %q", item)
+				}
+			}
+			p.Require[class] = n
+		}
+	} else if f.charset != "" {
+		// by default one character of each class present in the charset is required
+		p.Require = make(map[string]int)
+		for _, class := range passgen.Classes {
+			classChars, _ := passgen.ClassCharset(class)
+			if strings.ContainsAny(string(p.Charset), string(classChars)) {
+				p.Require[class] = 1
+			}
+		}
+	}
+
+	p.Forbidden = []rune(f.forbid)
+	p.NoAmbiguous = f.noAmbiguous
+	return p, nil
+}
+
+// generateWithPolicy generates a password satisfying the policy flags and reports its entropy to stderr.
+func generateWithPolicy(gen *passgen.Generator, flags policyFlags) (string, error) {
+	p, err := flags.policy()
+	if err != nil {
+		return "", err
+	}
+	pass, err := gen.GenerateWithPolicy(p)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "password entropy: at least %.1f bits\n", p.Entropy())
+	return pass, nil
+}
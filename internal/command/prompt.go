@@ -0,0 +1,66 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// passphraseEnv allows to pass the master passphrase in scripts.
+const passphraseEnv = "PASSY_PASSPHRASE"
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassphrase reads the master passphrase from the environment or from the terminal without echo.
+func readPassphrase(prompt string) ([]byte, error) {
+	if pass := os.Getenv(passphraseEnv); pass != "" {
+		return []byte(pass), nil
+	}
+	return readSecret(prompt)
+}
+
+// readSecret reads a line from the terminal without echo, or from stdin if it's not a terminal.
+func readSecret(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return nil, fmt.Errorf("failed to read %s: %v", strings.TrimSuffix(prompt, ": "), err)
+		}
+		return []byte(strings.TrimRight(line, "\r\n")), nil
+	}
+
+	pass, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", strings.TrimSuffix(prompt, ": "), err)
+	}
+	return pass, nil
+}
+
+// readNewPassphrase asks for a new passphrase twice and checks both inputs match.
+func readNewPassphrase() ([]byte, error) {
+	if pass := os.Getenv(passphraseEnv); pass != "" {
+		return []byte(pass), nil
+	}
+
+	pass, err := readPassphrase("new master passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	confirm, err := readPassphrase("repeat master passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if string(pass) != string(confirm) {
+		return nil, fmt.Errorf("passphrases do not match")
+	}
+	if len(pass) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	return pass, nil
+}
@@ -0,0 +1,273 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/storage"
+)
+
+func newRecipientsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recipients",
+		Short: "Share the vault with several people, each using their own identity key",
+		Long: `The vault content key is wrapped for the X25519 public key of each recipient,
+so nobody has to share a single symmetric key file.`,
+	}
+
+	var (
+		identity string
+		name     string
+	)
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Re-encrypt the vault for your identity, it's generated if the file doesn't exist",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleRecipientsInit(identity, name)
+		},
+	}
+	initCmd.Flags().StringVar(&identity, "identity", "", "path to your X25519 identity")
+	initCmd.Flags().StringVar(&name, "name", "owner", "your recipient name")
+	_ = initCmd.MarkFlagRequired("identity")
+
+	keygenCmd := &cobra.Command{
+		Use:   "keygen <path>",
+		Short: "Generate a new identity and print its public key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleIdentityKeygen(args[0])
+		},
+	}
+
+	lsCmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List vault recipients",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleRecipientsList()
+		},
+	}
+
+	useCmd := &cobra.Command{
+		Use:   "use",
+		Short: "Unlock the vault with your identity once a member added its public key",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleRecipientsUse(identity)
+		},
+	}
+	useCmd.Flags().StringVar(&identity, "identity", "", "path to your X25519 identity")
+	_ = useCmd.MarkFlagRequired("identity")
+
+	addCmd := &cobra.Command{
+		Use:   "add <name> <public-key>",
+		Short: "Give the public key access to the vault",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleRecipientsAdd(args[0], args[1])
+		},
+	}
+
+	rmCmd := &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Revoke recipient access, the vault is re-encrypted with a new content key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleRecipientsRemove(args[0])
+		},
+	}
+
+	cmd.AddCommand(initCmd, keygenCmd, useCmd, lsCmd, addCmd, rmCmd)
+	return cmd
+}
+
+func handleRecipientsInit(identityPath, name string) error {
+	identityPath, err := filepath.Abs(identityPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+	if st.UsesRecipients() {
+		return errors.New("the vault is already shared with recipients")
+	}
+
+	flds, err := st.Decrypt()
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt")
+	}
+	if err := requireOnline(st); err != nil {
+		return err
+	}
+
+	identity, err := readOrGenerateIdentity(identityPath)
+	if err != nil {
+		return err
+	}
+	if err := st.SetIdentity(identity, name); err != nil {
+		return err
+	}
+
+	msg := "share vault with recipients"
+	if err := encryptAndStore(st, flds, msg); err != nil {
+		return err
+	}
+
+	st.Cfg.IdentityPath = identityPath
+	st.Cfg.UsePassphrase = false
+	if err := st.Cfg.Save(); err != nil {
+		return errors.Wrap(err, "failed to update config")
+	}
+	return printPublicKey(identity)
+}
+
+// handleRecipientsUse points the config to the identity, if it can decrypt the vault.
+func handleRecipientsUse(identityPath string) error {
+	identityPath, err := filepath.Abs(identityPath)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := storage.LoadConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+	cfg.IdentityPath = identityPath
+	cfg.UsePassphrase = false
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	st, err := storage.New(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to init storage")
+	}
+	if _, err := st.Decrypt(); err != nil {
+		return errors.Wrap(err, "failed to decrypt with the identity, ask a member to run passy recipients add with your public key")
+	}
+	if st.Data == "" {
+		return errors.New("the vault is empty, run passy recipients init to share it")
+	}
+
+	if err := cfg.Save(); err != nil {
+		return errors.Wrap(err, "failed to update config")
+	}
+	fmt.Printf("the vault is now unlocked with the identity %s\n", identityPath)
+	return nil
+}
+
+func handleIdentityKeygen(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("file already exists: %s", path)
+	}
+
+	identity, err := readOrGenerateIdentity(path)
+	if err != nil {
+		return err
+	}
+	return printPublicKey(identity)
+}
+
+func handleRecipientsList() error {
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+	if !st.UsesRecipients() {
+		return errors.New("the vault is not shared with recipients, run passy recipients init")
+	}
+	if _, err := st.Decrypt(); err != nil {
+		return errors.Wrap(err, "failed to decrypt")
+	}
+
+	for _, r := range st.Recipients {
+		fmt.Printf("%s\t%s\n", r.Name, r)
+	}
+	return nil
+}
+
+func handleRecipientsAdd(name, publicKey string) error {
+	pub, err := storage.ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+	flds, err := st.Decrypt()
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt")
+	}
+	if err := st.AddRecipient(name, pub); err != nil {
+		return err
+	}
+
+	if err := encryptAndStore(st, flds, fmt.Sprintf("add vault recipient %s", name)); err != nil {
+		return err
+	}
+	fmt.Printf("%q can now decrypt the vault\n", name)
+	return nil
+}
+
+func handleRecipientsRemove(name string) error {
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+	flds, err := st.Decrypt()
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt")
+	}
+	if err := requireOnline(st); err != nil {
+		return err
+	}
+	if err := st.RemoveRecipient(name); err != nil {
+		return err
+	}
+
+	if err := encryptAndStore(st, flds, fmt.Sprintf("remove vault recipient %s", name)); err != nil {
+		return err
+	}
+	fmt.Printf("%q was removed, the vault was re-encrypted with a new content key\n", name)
+	fmt.Println("note: the old key still opens previous revisions in the git history, rotate the secrets it had access to")
+	return nil
+}
+
+// readOrGenerateIdentity reads the identity on the given path or generates a new one if the file doesn't exist.
+func readOrGenerateIdentity(path string) ([]byte, error) {
+	identity, err := os.ReadFile(path)
+	if err == nil {
+		return identity, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if identity, err = storage.GenerateIdentity(); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(path, identity, 0o600); err != nil {
+		return nil, err
+	}
+	fmt.Printf("the new identity was generated: %s\n", path)
+	return identity, nil
+}
+
+func printPublicKey(identity []byte) error {
+	pub, err := storage.PublicKey(identity)
+	if err != nil {
+		return errors.Wrap(err, "invalid identity")
+	}
+	fmt.Printf("public key: %s\n", storage.FormatPublicKey(pub))
+	return nil
+}
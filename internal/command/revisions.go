@@ -0,0 +1,140 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/storage"
+)
+
+// shortRevLen is the length of git commit hashes in the log output.
+const shortRevLen = 8
+
+func newLogCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "log",
+		Short: "List vault revisions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleLog()
+		},
+	}
+}
+
+func newShowCommand() *cobra.Command {
+	var at string
+
+	cmd := &cobra.Command{
+		Use:   "show [--at <rev>] <key>",
+		Short: "Show the key at the given revision of the vault",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleShow(at, args[0])
+		},
+	}
+	cmd.Flags().StringVar(&at, "at", "", "revision listed by passy log (the latest one by default)")
+
+	return cmd
+}
+
+func newDiffCommand() *cobra.Command {
+	var show bool
+
+	cmd := &cobra.Command{
+		Use:   "diff <revA> <revB>",
+		Short: "Show keys added, changed or removed between two revisions",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleDiff(args[0], args[1], show)
+		},
+	}
+	cmd.Flags().BoolVar(&show, "show", false, "show passwords of changed keys")
+
+	return cmd
+}
+
+func handleLog() error {
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+
+	revs, err := st.Log()
+	if err != nil {
+		return errors.Wrap(err, "failed to read the vault history")
+	}
+	for _, rev := range revs {
+		id := rev.ID
+		if len(id) > shortRevLen {
+			id = id[:shortRevLen]
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", id, rev.Time.Local().Format("2006-01-02 15:04:05"), rev.Author, rev.Message)
+	}
+	return nil
+}
+
+func handleShow(rev, key string) error {
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+
+	var flds *storage.Folder
+	if rev == "" {
+		flds, err = st.Decrypt()
+	} else {
+		flds, err = st.DecryptAt(rev)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt")
+	}
+
+	sf, found := flds.GetSubFolder(key)
+	if !found {
+		return fmt.Errorf("no such key: %s", key)
+	}
+	fmt.Println(sf.String("")())
+	return nil
+}
+
+func handleDiff(revA, revB string, show bool) error {
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+
+	a, err := st.DecryptAt(revA)
+	if err != nil {
+		return err
+	}
+	b, err := st.DecryptAt(revB)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range storage.DiffFolders(a, b) {
+		switch {
+		case c.Old == nil:
+			fmt.Printf("+ %s%s\n", c.Key, diffValue(show, c.New.Pass))
+		case c.New == nil:
+			fmt.Printf("- %s%s\n", c.Key, diffValue(show, c.Old.Pass))
+		default:
+			value := ""
+			if show {
+				value = fmt.Sprintf(": %s -> %s", c.Old.Pass, c.New.Pass)
+			}
+			fmt.Printf("~ %s%s\n", c.Key, value)
+		}
+	}
+	return nil
+}
+
+// diffValue returns the password suffix of a diff line if passwords are shown.
+func diffValue(show bool, pass string) string {
+	if !show {
+		return ""
+	}
+	return ": " + pass
+}
@@ -0,0 +1,106 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/storage"
+)
+
+func newRotateKeyCommand() *cobra.Command {
+	var newKey string
+
+	cmd := &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Re-encrypt the vault with a new key",
+		Long: `Decrypts the vault with the current key, re-encrypts it with the new one and updates the config.
+A new key is generated if there is no file on the given path.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleRotateKey(newKey)
+		},
+	}
+
+	cmd.Flags().StringVar(&newKey, "new-key", "", "path to the new private encryption key")
+	_ = cmd.MarkFlagRequired("new-key")
+
+	return cmd
+}
+
+func handleRotateKey(newKeyPath string) error {
+	newKeyPath, err := filepath.Abs(newKeyPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+
+	flds, err := st.Decrypt()
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt with the current key")
+	}
+	if err := requireOnline(st); err != nil {
+		return err
+	}
+
+	key, err := readOrGenerateKey(newKeyPath)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(key, st.PrivKey) {
+		return errors.New("the new key is the same as the current one")
+	}
+
+	if err := st.Rekey(key); err != nil {
+		return err
+	}
+	if err := st.Encrypt(flds); err != nil {
+		return errors.Wrap(err, "failed to encrypt with the new key")
+	}
+
+	msg := "rotate vault encryption key"
+	if err := storeVault(st, &msg); err != nil {
+		if errors.Is(err, storage.ErrRemoteChanged) || errors.Is(err, storage.ErrOffline) {
+			return errors.Wrap(err, "the key was not rotated")
+		}
+		return errors.Wrap(err, "failed to store re-encrypted vault")
+	}
+
+	st.Cfg.PrivKeyPath = newKeyPath
+	st.Cfg.UsePassphrase = false
+	st.Cfg.IdentityPath = ""
+	if err := st.Cfg.Save(); err != nil {
+		return errors.Wrapf(err, "the vault is encrypted with %s, but the config was not updated", newKeyPath)
+	}
+
+	fmt.Printf("the vault key was rotated, new key: %s\n", newKeyPath)
+	return nil
+}
+
+// readOrGenerateKey reads the key on the given path or generates a new one if the file doesn't exist.
+func readOrGenerateKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if key, err = storage.GenerateAESKey(32); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(path, key, 0o600); err != nil {
+		return nil, err
+	}
+	fmt.Printf("the new key was generated: %s\n", path)
+	return key, nil
+}
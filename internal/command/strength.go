@@ -0,0 +1,119 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/hibp"
+	"github.com/koss-null/passy/internal/passgen"
+	"github.com/koss-null/passy/internal/storage"
+)
+
+func newStrengthCommand() *cobra.Command {
+	var readable, safe, insane bool
+
+	cmd := &cobra.Command{
+		Use:   "strength [password]",
+		Short: "Estimate the password strength, it's asked without echo if not given",
+		Long: `Estimates the number of guesses needed to find the password in bits of entropy, taking into account
+common passwords, dictionary words, keyboard walks, repeats, sequences and dates.
+With --readable, --safe or --insane the theoretical entropy of passwords generated with the level is printed instead.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case readable:
+				return handleLevelStrength("readable", passgen.ReadableEntropy())
+			case safe:
+				return handleLevelStrength("safe", passgen.SafeEntropy())
+			case insane:
+				return handleLevelStrength("insane", passgen.InsaneEntropy())
+			}
+
+			var pass string
+			if len(args) == 1 {
+				pass = args[0]
+			} else {
+				secret, err := readSecret("password: ")
+				if err != nil {
+					return err
+				}
+				pass = string(secret)
+			}
+			return handleStrength(pass)
+		},
+	}
+
+	cmd.Flags().BoolVar(&readable, "readable", false, "print the entropy of generated readable passwords")
+	cmd.Flags().BoolVar(&safe, "safe", false, "print the entropy of generated safe passwords")
+	cmd.Flags().BoolVar(&insane, "insane", false, "print the entropy of generated insane passwords")
+	cmd.MarkFlagsMutuallyExclusive("readable", "safe", "insane")
+	return cmd
+}
+
+func handleStrength(pass string) error {
+	s := passgen.EstimateStrength(pass)
+	fmt.Printf("entropy: %.1f bits (%s)\n", s.Entropy, s.Level())
+	if len(s.Warnings) > 0 {
+		fmt.Printf("patterns: %s\n", strings.Join(s.Warnings, ", "))
+	}
+	return nil
+}
+
+func handleLevelStrength(level string, entropy float64) error {
+	s := passgen.Strength{Entropy: entropy}
+	fmt.Printf("%s passwords entropy: at least %.1f bits (%s)\n", level, s.Entropy, s.Level())
+	return nil
+}
+
+// generateLevel generates a password with the strength level recipe and reports its entropy to stderr,
+// so it doesn't mix with the output.
+func generateLevel(generate func() (string, error), entropy float64) (string, error) {
+	pass, err := generate()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "password entropy: at least %.1f bits\n", entropy)
+	return pass, nil
+}
+
+// minEntropy returns the configured strength threshold.
+func minEntropy(cfg *storage.Config) float64 {
+	if cfg.MinEntropy == 0 {
+		return passgen.FairEntropy
+	}
+	return cfg.MinEntropy
+}
+
+// warnWeakPassword warns when the password is weaker than the configured MinEntropy.
+func warnWeakPassword(cfg *storage.Config, pass string) {
+	minEntropy := minEntropy(cfg)
+	s := passgen.EstimateStrength(pass)
+	if s.Entropy >= minEntropy {
+		return
+	}
+	msg := fmt.Sprintf("warning: the password is %s, %.1f bits of entropy is less than %.0f", s.Level(), s.Entropy, minEntropy)
+	if len(s.Warnings) > 0 {
+		msg += " (" + strings.Join(s.Warnings, ", ") + ")"
+	}
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+// warnBreachedPassword warns when the password is in the configured BreachFile.
+func warnBreachedPassword(cfg *storage.Config, pass string) {
+	if cfg.BreachFile == "" {
+		return
+	}
+	list, err := hibp.Open(cfg.BreachFile)
+	if err == nil {
+		var count int
+		if count, err = list.Count(pass); err == nil && count > 0 {
+			fmt.Fprintf(os.Stderr, "warning: the password was seen %d times in data breaches\n", count)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to check the password for breaches: %v\n", err)
+	}
+}
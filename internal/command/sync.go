@@ -0,0 +1,125 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/koss-null/passy/internal/storage"
+)
+
+func newSyncCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sync",
+		Short: "Push changes committed while the remote was unreachable",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handleSync()
+		},
+	}
+}
+
+func handleSync() error {
+	st, err := openStorage()
+	if err != nil {
+		return err
+	}
+
+	pending, err := st.Pending()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d commits pending\n", pending)
+
+	pushed, err := st.Sync()
+	if err != nil {
+		return errors.Wrap(err, "failed to sync")
+	}
+	if pushed > 0 {
+		fmt.Printf("%d commits pushed\n", pushed)
+	}
+	fmt.Println("the vault is up to date")
+	return nil
+}
+
+// storeVault stores the vault and reports if the change was only committed locally.
+func storeVault(st *storage.Storage, msg *string) error {
+	if err := st.Store(msg); err != nil {
+		return err
+	}
+	if st.Offline || st.PushErr != nil {
+		pending, err := st.Pending()
+		if err != nil {
+			return err
+		}
+		reason := "the remote is unreachable"
+		if st.PushErr != nil {
+			reason = st.PushErr.Error()
+		}
+		fmt.Fprintf(os.Stderr, "%s, the change is committed locally (%d commits pending), run passy sync later\n", reason, pending)
+	}
+	return nil
+}
+
+// requireOnline refuses to change the vault key from the local copy, the new key can't be merged
+// with revisions others push meanwhile.
+func requireOnline(st *storage.Storage) error {
+	if st.Offline {
+		return errors.Wrap(storage.ErrOffline, "the vault key can't be changed offline")
+	}
+	return nil
+}
+
+// encryptAndStore encrypts the folders and stores the vault with the given commit message.
+func encryptAndStore(st *storage.Storage, flds *storage.Folder, msg string) error {
+	if err := st.Encrypt(flds); err != nil {
+		return errors.Wrap(err, "failed to encrypt")
+	}
+	if err := storeVault(st, &msg); err != nil {
+		return errors.Wrap(err, "failed to store the vault")
+	}
+	return nil
+}
+
+// resolveConflict asks which version of an entry changed both locally and remotely to keep.
+func resolveConflict(c storage.Conflict) (*storage.Folder, error) {
+	describe := func(entry *storage.Folder) string {
+		switch {
+		case entry == nil:
+			return "deleted"
+		case c.Base == nil:
+			return "added"
+		default:
+			return "changed"
+		}
+	}
+
+	fmt.Printf("conflict on %q: %s locally, %s remotely\n", c.Key, describe(c.Ours), describe(c.Theirs))
+	for {
+		fmt.Print("keep (l)ocal or (r)emote version? [l/r]: ")
+		ans, err := stdin.ReadString('\n')
+		if err != nil && ans == "" {
+			return nil, errors.Wrap(err, "failed to read the answer")
+		}
+		switch strings.TrimSpace(ans) {
+		case "l", "L", "local":
+			return c.Ours, nil
+		case "r", "R", "remote":
+			return c.Theirs, nil
+		}
+	}
+}
+
+// warnOffline warns that the data may be outdated when the remote is unreachable.
+func warnOffline(st *storage.Storage) {
+	if !st.Offline {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "the remote is unreachable, showing the locally cached vault")
+	if pending, err := st.Pending(); err == nil && pending > 0 {
+		fmt.Fprintf(os.Stderr, "%d commits pending, run passy sync\n", pending)
+	}
+}
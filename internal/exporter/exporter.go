@@ -0,0 +1,148 @@
+// Package exporter writes the vault entries in formats other tools read.
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+
+	"github.com/koss-null/passy/internal/storage"
+)
+
+// Export formats.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatPass = "pass"
+)
+
+// Formats lists the export formats.
+var Formats = []string{FormatJSON, FormatCSV, FormatPass}
+
+// Entry is a vault entry with its full key.
+type Entry struct {
+	Key      string       `json:"key"`
+	Password string       `json:"password,omitempty"`
+	Username string       `json:"username,omitempty"`
+	URL      string       `json:"url,omitempty"`
+	Notes    string       `json:"notes,omitempty"`
+	OTP      string       `json:"otp,omitempty"`
+	Fields   []Field      `json:"fields,omitempty"`
+	History  []PassRecord `json:"history,omitempty"`
+	Changed  *time.Time   `json:"changed,omitempty"`
+}
+
+// Field is a custom field of an entry.
+type Field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// PassRecord is a previous password of an entry.
+type PassRecord struct {
+	Password string    `json:"password"`
+	Time     time.Time `json:"time"`
+}
+
+// Entries returns the entries of the vault tree in the tree order.
+func Entries(root *storage.Folder) []Entry {
+	var entries []Entry
+	for _, key := range root.Keys() {
+		f, _ := root.GetSubFolder(key)
+		e := Entry{
+			Key:      key,
+			Password: f.Pass,
+			Username: f.Username,
+			URL:      f.URL,
+			Notes:    f.Notes,
+			OTP:      f.OTP,
+			Changed:  f.Changed,
+		}
+		for _, field := range f.Fields {
+			e.Fields = append(e.Fields, Field{Name: field.Name, Value: field.Value, Secret: field.Secret})
+		}
+		for _, r := range f.History {
+			e.History = append(e.History, PassRecord{Password: r.Pass, Time: r.Time})
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// WriteJSON writes the entries as a JSON array.
+func WriteJSON(w io.Writer, entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(entries)
+}
+
+// csvHeader are the CSV columns before the custom fields, each custom field name gets its own column.
+var csvHeader = []string{"key", "username", "password", "url", "notes", "otp"}
+
+// WriteCSV writes the entries with a header row, the history is left out.
+func WriteCSV(w io.Writer, entries []Entry) error {
+	header := append([]string{}, csvHeader...)
+	column := make(map[string]int)
+	for _, e := range entries {
+		for _, f := range e.Fields {
+			if _, ok := column[f.Name]; !ok {
+				column[f.Name] = len(header)
+				header = append(header, f.Name)
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		record := make([]string, len(header))
+		copy(record, []string{e.Key, e.Username, e.Password, e.URL, e.Notes, e.OTP})
+		for _, f := range e.Fields {
+			record[column[f.Name]] = f.Value
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Encrypt returns a writer encrypting to the passphrase as an armored OpenPGP message,
+// which gpg --decrypt reads. Close finishes the message, the underlying writer is not closed.
+func Encrypt(w io.Writer, passphrase []byte) (io.WriteCloser, error) {
+	aw, err := armor.Encode(w, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+	pw, err := openpgp.SymmetricallyEncrypt(aw, passphrase, nil, &packet.Config{DefaultCipher: packet.CipherAES256})
+	if err != nil {
+		return nil, err
+	}
+	return &chainCloser{WriteCloser: pw, next: aw}, nil
+}
+
+// chainCloser closes the next writer after its own.
+type chainCloser struct {
+	io.WriteCloser
+	next io.Closer
+}
+
+func (c *chainCloser) Close() error {
+	if err := c.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return c.next.Close()
+}
@@ -0,0 +1,130 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+)
+
+const (
+	passFileExt   = ".gpg"
+	passIDFile    = ".gpg-id"
+	passFilePerm  = 0o600
+	passDirPerm   = 0o700
+	pgpArmorStart = "-----BEGIN PGP"
+)
+
+// ReadRecipients reads OpenPGP public keys, armored or binary, as exported by gpg --export.
+func ReadRecipients(path string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the recipient key")
+	}
+
+	var keyring openpgp.EntityList
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(pgpArmorStart)) {
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the recipient key")
+	}
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("%s has no keys", path)
+	}
+	return keyring, nil
+}
+
+// WritePassStore writes the entries as a pass (password-store) directory encrypted to the recipients:
+// the first line of a file is the password, then login:, url:, otpauth:// and custom fields, then notes.
+// The directory must be empty or not exist. Keys which can't be file paths are returned as skipped.
+func WritePassStore(dir string, entries []Entry, recipients openpgp.EntityList) ([]string, error) {
+	if files, err := os.ReadDir(dir); err == nil && len(files) > 0 {
+		return nil, fmt.Errorf("%s is not empty", dir)
+	}
+	if err := os.MkdirAll(dir, passDirPerm); err != nil {
+		return nil, errors.Wrap(err, "failed to create the password store")
+	}
+
+	var ids strings.Builder
+	for _, e := range recipients {
+		fmt.Fprintf(&ids, "%X\n", e.PrimaryKey.Fingerprint)
+	}
+	if err := os.WriteFile(filepath.Join(dir, passIDFile), []byte(ids.String()), passFilePerm); err != nil {
+		return nil, errors.Wrap(err, "failed to write "+passIDFile)
+	}
+
+	var skipped []string
+	for _, e := range entries {
+		if !validPath(e.Key) {
+			skipped = append(skipped, e.Key)
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(e.Key)+passFileExt)
+		if err := os.MkdirAll(filepath.Dir(path), passDirPerm); err != nil {
+			return nil, errors.Wrap(err, "failed to create the password store")
+		}
+		if err := writePassFile(path, passContent(e), recipients); err != nil {
+			return nil, errors.Wrapf(err, "failed to write %s", e.Key)
+		}
+	}
+	return skipped, nil
+}
+
+// validPath checks every part of the key is a plain file name.
+func validPath(key string) bool {
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `\`+"\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+// passContent lays the entry out the way pass extensions and browserpass read it.
+func passContent(e Entry) string {
+	var sb strings.Builder
+	sb.WriteString(e.Password + "\n")
+	if e.Username != "" {
+		sb.WriteString("login: " + e.Username + "\n")
+	}
+	if e.URL != "" {
+		sb.WriteString("url: " + e.URL + "\n")
+	}
+	if e.OTP != "" {
+		sb.WriteString(e.OTP + "\n")
+	}
+	for _, f := range e.Fields {
+		sb.WriteString(f.Name + ": " + strings.ReplaceAll(f.Value, "\n", " ") + "\n")
+	}
+	if e.Notes != "" {
+		sb.WriteString(e.Notes + "\n")
+	}
+	return sb.String()
+}
+
+func writePassFile(path, content string, recipients openpgp.EntityList) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, passFilePerm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := openpgp.Encrypt(f, recipients, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,160 @@
+// Package hibp looks up passwords in a locally downloaded Have I Been Pwned
+// Pwned Passwords SHA-1 list, nothing is sent over the network.
+//
+// Two layouts are supported, both sorted by hash as published:
+//   - a single file ordered by hash with "HASH:COUNT" lines (pwned-passwords-sha1-ordered-by-hash);
+//   - a directory of range files named by the 5 hex characters hash prefix with "SUFFIX:COUNT" lines,
+//     as written by the haveibeenpwned-downloader.
+package hibp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	hashLen   = sha1.Size * 2
+	prefixLen = 5
+	// maxLineLen is more than a hash, a colon, a breach count and CRLF
+	maxLineLen = 64
+)
+
+// List is a local Pwned Passwords list.
+type List struct {
+	path string
+	dir  bool
+}
+
+// Open checks the list on the path, a file ordered by hash or a directory of range files.
+func Open(path string) (*List, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the breached passwords list")
+	}
+
+	l := &List{path: path, dir: info.IsDir()}
+	sample, keyLen := path, hashLen
+	if l.dir {
+		sample, keyLen = filepath.Join(path, "00000.txt"), hashLen-prefixLen
+	}
+	if err := checkFormat(sample, keyLen); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// checkFormat checks the first line of the file is a SHA-1 hash or suffix with a count.
+func checkFormat(path string, keyLen int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the breached passwords list")
+	}
+	defer f.Close()
+
+	line, err := lineAt(f, 0)
+	if err != nil {
+		return err
+	}
+	key, _, ok := strings.Cut(line, ":")
+	if !ok || len(key) != keyLen {
+		return fmt.Errorf("%s is not a SHA-1 Pwned Passwords list, NTLM lists are not supported", path)
+	}
+	return nil
+}
+
+// Count returns how many times the password appears in breaches, 0 if it's not in the list.
+func (l *List) Count(pass string) (int, error) {
+	sum := sha1.Sum([]byte(pass))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	path, key := l.path, hash
+	if l.dir {
+		path, key = filepath.Join(l.path, hash[:prefixLen]+".txt"), hash[prefixLen:]
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if l.dir && os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, errors.Wrap(err, "failed to open the breached passwords list")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return search(f, info.Size(), key)
+}
+
+// search finds the key in the sorted lines with a binary search over byte offsets.
+func search(r io.ReaderAt, size int64, key string) (int, error) {
+	// find the smallest offset whose next line is not less than the key
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, err := lineAt(r, mid)
+		if err != nil {
+			return 0, err
+		}
+		if line == "" || lineKey(line) >= key {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	line, err := lineAt(r, lo)
+	if err != nil || lineKey(line) != key {
+		return 0, err
+	}
+	_, count, _ := strings.Cut(line, ":")
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil {
+		return 0, fmt.Errorf("invalid breach count in line %q", line)
+	}
+	return n, nil
+}
+
+// lineAt returns the first line starting at the offset or after it, empty at the end of the file.
+func lineAt(r io.ReaderAt, off int64) (string, error) {
+	start := off
+	if start > 0 {
+		// the line starts at the offset if the previous byte is a line break
+		start--
+	}
+
+	buf := make([]byte, 2*maxLineLen)
+	n, err := r.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return "", errors.Wrap(err, "failed to read the breached passwords list")
+	}
+	buf = buf[:n]
+
+	if off > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return "", nil
+		}
+		buf = buf[i+1:]
+	}
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		buf = buf[:i]
+	}
+	return strings.TrimRight(string(buf), "\r"), nil
+}
+
+func lineKey(line string) string {
+	key, _, _ := strings.Cut(line, ":")
+	return strings.ToUpper(key)
+}
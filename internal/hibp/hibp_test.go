@@ -0,0 +1,141 @@
+package hibp
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// breach is a password of the test lists and its breach count.
+type breach struct {
+	pass  string
+	hash  string
+	count int
+}
+
+// testBreaches returns passwords sorted by hash, counts have different lengths so lines do too.
+func testBreaches() []breach {
+	var res []breach
+	for i := range 300 {
+		pass := fmt.Sprintf("pass%d", i)
+		sum := sha1.Sum([]byte(pass))
+		res = append(res, breach{pass: pass, hash: strings.ToUpper(hex.EncodeToString(sum[:])), count: i*i*37 + 1})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].hash < res[j].hash })
+	return res
+}
+
+func writeLines(t *testing.T, path string, lines []string, eol string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, eol)+eol), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkList looks up every password of the list, the first, the middle and the last line among them.
+func checkList(t *testing.T, l *List, breaches []breach) {
+	t.Helper()
+	for _, b := range breaches {
+		count, err := l.Count(b.pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != b.count {
+			t.Errorf("%s (%s): got %d, want %d", b.pass, b.hash, count, b.count)
+		}
+	}
+	for _, pass := range []string{"not breached", "", "pass300"} {
+		count, err := l.Count(pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%q: got %d, want 0", pass, count)
+		}
+	}
+}
+
+func TestOrderedFile(t *testing.T) {
+	breaches := testBreaches()
+	for _, eol := range []string{"\n", "\r\n"} {
+		var lines []string
+		for _, b := range breaches {
+			lines = append(lines, fmt.Sprintf("%s:%d", b.hash, b.count))
+		}
+		path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+		writeLines(t, path, lines, eol)
+
+		l, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkList(t, l, breaches)
+	}
+}
+
+func TestSingleLine(t *testing.T) {
+	b := testBreaches()[0]
+	path := filepath.Join(t.TempDir(), "list.txt")
+	writeLines(t, path, []string{fmt.Sprintf("%s:%d", strings.ToLower(b.hash), b.count)}, "\n")
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkList(t, l, []breach{b})
+}
+
+func TestRangeDirectory(t *testing.T) {
+	breaches := testBreaches()
+	dir := t.TempDir()
+	ranges := map[string][]string{
+		// the downloader writes every range, the format is checked by the first one
+		"00000": {"0005AD76BD555C1D6D771DE417A4B87E4B4:10"},
+	}
+	for _, b := range breaches {
+		prefix := b.hash[:prefixLen]
+		ranges[prefix] = append(ranges[prefix], fmt.Sprintf("%s:%d", b.hash[prefixLen:], b.count))
+	}
+	for prefix, lines := range ranges {
+		writeLines(t, filepath.Join(dir, prefix+".txt"), lines, "\r\n")
+	}
+
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkList(t, l, breaches)
+}
+
+func TestOpenInvalid(t *testing.T) {
+	dir := t.TempDir()
+	ntlm := filepath.Join(dir, "ntlm.txt")
+	writeLines(t, ntlm, []string{"32ED87BDB5FDC5E9CBA88547376818D4:100"}, "\n")
+	empty := filepath.Join(dir, "empty.txt")
+	writeLines(t, empty, nil, "")
+
+	for _, path := range []string{ntlm, empty, filepath.Join(dir, "missing.txt"), dir} {
+		if _, err := Open(path); err == nil {
+			t.Errorf("%s is opened as a list", path)
+		}
+	}
+}
+
+func TestInvalidCount(t *testing.T) {
+	b := testBreaches()[0]
+	path := filepath.Join(t.TempDir(), "list.txt")
+	writeLines(t, path, []string{b.hash + ":many"}, "\n")
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Count(b.pass); err == nil {
+		t.Error("an invalid count is read")
+	}
+}
@@ -0,0 +1,270 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/koss-null/passy/internal/otp"
+	"github.com/koss-null/passy/internal/storage"
+)
+
+// CSV export formats.
+const (
+	FormatChrome    = "chrome"
+	FormatFirefox   = "firefox"
+	FormatBitwarden = "bitwarden"
+	Format1Password = "1password"
+	FormatGeneric   = "generic"
+)
+
+// CSVFormats lists the CSV formats.
+var CSVFormats = []string{FormatChrome, FormatFirefox, FormatBitwarden, Format1Password, FormatGeneric}
+
+// Entry values a CSV column can be mapped to. csvFields holds "name: value" lines of custom fields.
+const (
+	csvName     = "name"
+	csvURL      = "url"
+	csvUsername = "username"
+	csvPassword = "password"
+	csvNotes    = "notes"
+	csvOTP      = "otp"
+	csvFields   = "fields"
+)
+
+// CSVColumns lists the values a generic CSV column can be mapped to.
+var CSVColumns = []string{csvName, csvURL, csvUsername, csvPassword, csvNotes, csvOTP}
+
+// csvFormat maps entry values to the column names of an export, lowercase.
+type csvFormat struct {
+	columns map[string]string
+	// marker is a column only this format has, to detect it by the header.
+	marker string
+}
+
+// csvFormats are in the detection order, Chrome's columns are a subset of the others.
+var csvFormats = []struct {
+	name   string
+	format csvFormat
+}{
+	{FormatBitwarden, csvFormat{marker: "login_uri", columns: map[string]string{
+		csvName: "name", csvURL: "login_uri", csvUsername: "login_username", csvPassword: "login_password",
+		csvNotes: "notes", csvOTP: "login_totp", csvFields: "fields",
+	}}},
+	{FormatFirefox, csvFormat{marker: "formactionorigin", columns: map[string]string{
+		csvURL: "url", csvUsername: "username", csvPassword: "password",
+	}}},
+	{Format1Password, csvFormat{marker: "otpauth", columns: map[string]string{
+		csvName: "title", csvURL: "url", csvUsername: "username", csvPassword: "password",
+		csvNotes: "notes", csvOTP: "otpauth",
+	}}},
+	{FormatChrome, csvFormat{marker: "name", columns: map[string]string{
+		csvName: "name", csvURL: "url", csvUsername: "username", csvPassword: "password", csvNotes: "note",
+	}}},
+}
+
+// CSVOptions select the CSV layout.
+type CSVOptions struct {
+	// Format is one of CSVFormats, it's detected by the header if empty.
+	Format string
+	// Columns maps CSVColumns to column names or 1-based numbers for FormatGeneric.
+	// Other columns of the generic format become custom fields.
+	Columns map[string]string
+}
+
+// ReadCSV reads a password export with a header row. Every row becomes an entry keyed by the site
+// host and the username, e.g. github.com/alice; rows without a URL are keyed by their name.
+func ReadCSV(r io.Reader, opts CSVOptions) (*Result, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CSV")
+	}
+	if len(records) == 0 {
+		return nil, errors.New("the CSV file is empty")
+	}
+
+	header := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+	}
+	format, columns, extra, err := csvColumns(header, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	// custom fields can't use the built-in names, such columns are reported once instead of every row
+	extra = slices.DeleteFunc(extra, func(col int) bool {
+		if !storage.IsBuiltinField(header[col]) {
+			return false
+		}
+		res.Skipped = append(res.Skipped, fmt.Sprintf("column %q: %q is a built-in field, map the column to import it", records[0][col], header[col]))
+		return true
+	})
+	for i, record := range records[1:] {
+		row := i + 2
+		value := func(name string) string {
+			if col, ok := columns[name]; ok && col < len(record) {
+				return strings.TrimSpace(record[col])
+			}
+			return ""
+		}
+
+		entry := &storage.Folder{
+			Pass:     value(csvPassword),
+			Username: value(csvUsername),
+			URL:      value(csvURL),
+			Notes:    value(csvNotes),
+		}
+		if s := value(csvOTP); s != "" {
+			if totp, err := otp.Parse(s); err != nil {
+				res.Skipped = append(res.Skipped, fmt.Sprintf("row %d: TOTP secret: %v", row, err))
+			} else {
+				entry.OTP = totp.URI()
+			}
+		}
+		// Bitwarden joins several URIs with commas, other formats keep a single URL which may have commas
+		if uris := strings.Split(entry.URL, ","); format == FormatBitwarden && len(uris) > 1 {
+			entry.URL = strings.TrimSpace(uris[0])
+			for j, uri := range uris[1:] {
+				res.Skipped = append(res.Skipped, setCSVField(entry, row, fmt.Sprintf("url%d", j+2), strings.TrimSpace(uri))...)
+			}
+		}
+		for _, line := range strings.Split(value(csvFields), "\n") {
+			if name, v, ok := strings.Cut(line, ":"); ok {
+				res.Skipped = append(res.Skipped, setCSVField(entry, row, strings.TrimSpace(name), strings.TrimSpace(v))...)
+			}
+		}
+		for _, col := range extra {
+			if col < len(record) && strings.TrimSpace(record[col]) != "" {
+				res.Skipped = append(res.Skipped, setCSVField(entry, row, records[0][col], strings.TrimSpace(record[col]))...)
+			}
+		}
+
+		if entry.Pass == "" && entry.Username == "" && entry.Notes == "" && entry.OTP == "" && len(entry.Fields) == 0 {
+			res.Skipped = append(res.Skipped, fmt.Sprintf("row %d: no values to import", row))
+			continue
+		}
+		res.Entries = append(res.Entries, Entry{Key: csvKey(value(csvName), entry), Values: entry})
+	}
+	return res, nil
+}
+
+// csvColumns returns the detected or selected format, the column indexes of entry values
+// and the extra columns kept as custom fields.
+func csvColumns(header []string, opts CSVOptions) (string, map[string]int, []int, error) {
+	index := make(map[string]int)
+	for i, name := range header {
+		if _, ok := index[name]; !ok {
+			index[name] = i
+		}
+	}
+
+	if opts.Format == FormatGeneric {
+		if len(opts.Columns) == 0 {
+			return "", nil, nil, fmt.Errorf("the generic format needs columns of %s", strings.Join(CSVColumns, ", "))
+		}
+		columns := make(map[string]int)
+		for name, col := range opts.Columns {
+			if !slices.Contains(CSVColumns, name) {
+				return "", nil, nil, fmt.Errorf("unknown column %q, use one of %s", name, strings.Join(CSVColumns, ", "))
+			}
+			i, ok := index[strings.ToLower(col)]
+			if n, err := strconv.Atoi(col); err == nil {
+				i, ok = n-1, n >= 1 && n <= len(header)
+			}
+			if !ok {
+				return "", nil, nil, fmt.Errorf("no column %q in the CSV header", col)
+			}
+			columns[name] = i
+		}
+
+		mapped := make(map[int]bool)
+		for _, i := range columns {
+			mapped[i] = true
+		}
+		var extra []int
+		for i := range header {
+			if !mapped[i] {
+				extra = append(extra, i)
+			}
+		}
+		return FormatGeneric, columns, extra, nil
+	}
+
+	var (
+		name   string
+		format *csvFormat
+	)
+	for _, f := range csvFormats {
+		if f.name == opts.Format || opts.Format == "" && hasColumn(index, f.format.marker) {
+			name, format = f.name, &f.format
+			break
+		}
+	}
+	if format == nil {
+		if opts.Format != "" {
+			return "", nil, nil, fmt.Errorf("unknown CSV format %q, use one of %s", opts.Format, strings.Join(CSVFormats, ", "))
+		}
+		return "", nil, nil, errors.New("unknown CSV layout, set the format or map the columns of the generic one")
+	}
+
+	columns := make(map[string]int)
+	for value, col := range format.columns {
+		if i, ok := index[col]; ok {
+			columns[value] = i
+		}
+	}
+	if _, ok := columns[csvPassword]; !ok {
+		return "", nil, nil, errors.New("no password column in the CSV header")
+	}
+	return name, columns, nil, nil
+}
+
+func setCSVField(entry *storage.Folder, row int, name, value string) []string {
+	if err := entry.SetField(name, value, false); err != nil {
+		return []string{fmt.Sprintf("row %d: field %q: %v", row, name, err)}
+	}
+	return nil
+}
+
+// csvKey is host/username, the name replaces the host of entries without a URL.
+func csvKey(name string, entry *storage.Folder) string {
+	key := keyName(name)
+	if host := siteHost(entry.URL); host != "" {
+		key = host
+	}
+	if entry.Username != "" {
+		key += "/" + keyName(entry.Username)
+	}
+	return key
+}
+
+// siteHost returns the host of the URL without www., URLs may miss the scheme.
+func siteHost(s string) string {
+	if s == "" {
+		return ""
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	return keyName(strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."))
+}
+
+func hasColumn(index map[string]int, name string) bool {
+	_, ok := index[name]
+	return ok
+}
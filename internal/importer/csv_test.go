@@ -0,0 +1,72 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadCSVURLs(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		csv    string
+		url    string
+		fields int
+	}{
+		{
+			name: "bitwarden splits URIs",
+			csv: "name,login_uri,login_username,login_password\n" +
+				`mail,"https://mail.example,https://webmail.example",bob,s3cret` + "\n",
+			url:    "https://mail.example",
+			fields: 1,
+		},
+		{
+			name:   "chrome keeps commas",
+			format: FormatChrome,
+			csv:    "name,url,username,password\n" + `mail,"https://mail.example/?a=1,2",bob,s3cret` + "\n",
+			url:    "https://mail.example/?a=1,2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ReadCSV(strings.NewReader(tt.csv), CSVOptions{Format: tt.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Entries) != 1 || len(res.Skipped) != 0 {
+				t.Fatalf("entries %+v, skipped %v", res.Entries, res.Skipped)
+			}
+			entry := res.Entries[0].Values
+			if entry.URL != tt.url || len(entry.Fields) != tt.fields {
+				t.Errorf("url %q, fields %+v, want %q and %d fields", entry.URL, entry.Fields, tt.url, tt.fields)
+			}
+		})
+	}
+}
+
+func TestReadCSVGenericExtra(t *testing.T) {
+	csv := "Title,Secret,URL,PIN\n" +
+		"mail,s3cret,https://mail.example,1234\n" +
+		"bank,pass,,\n"
+	res, err := ReadCSV(strings.NewReader(csv), CSVOptions{
+		Format:  FormatGeneric,
+		Columns: map[string]string{csvName: "title", csvPassword: "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the unmapped URL column is reported once, the empty PIN is not a field
+	if len(res.Skipped) != 1 || !strings.Contains(res.Skipped[0], `"URL"`) {
+		t.Errorf("skipped %q, want the URL column once", res.Skipped)
+	}
+	if len(res.Entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(res.Entries))
+	}
+	mail, bank := res.Entries[0].Values, res.Entries[1].Values
+	if len(mail.Fields) != 1 || mail.Fields[0].Name != "PIN" || mail.Fields[0].Value != "1234" {
+		t.Errorf("mail fields %+v, want the PIN", mail.Fields)
+	}
+	if len(bank.Fields) != 0 {
+		t.Errorf("bank fields %+v, want none", bank.Fields)
+	}
+}
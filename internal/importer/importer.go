@@ -0,0 +1,106 @@
+// Package importer reads entries exported by other password managers.
+package importer
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/koss-null/passy/internal/storage"
+)
+
+// Conflict policies for imported keys which exist in the vault or repeat in the import.
+const (
+	ConflictFail    = "fail"
+	ConflictSkip    = "skip"
+	ConflictReplace = "replace"
+	ConflictRename  = "rename"
+)
+
+// Conflicts lists the conflict policies.
+var Conflicts = []string{ConflictFail, ConflictSkip, ConflictReplace, ConflictRename}
+
+// Entry is an imported entry, Values holds its fields without Name and SubFolder.
+type Entry struct {
+	Key    string
+	Values *storage.Folder
+}
+
+// Result is what an importer read.
+type Result struct {
+	Entries []Entry
+	// Skipped describes what couldn't be imported.
+	Skipped []string
+}
+
+// Applied describes how the entries were added to the vault.
+type Applied struct {
+	// Stored are the entries by the keys they were stored at.
+	Stored   []Entry
+	Replaced []string
+	// Renamed are pairs of the imported key and the key the entry was stored at.
+	Renamed [][2]string
+	// Skipped are keys which existed with ConflictSkip.
+	Skipped []string
+	// Duplicates are keys repeated in the import with the same values, they are stored once.
+	Duplicates []string
+}
+
+// Apply adds the entries to the vault tree resolving conflicts by the policy. With ConflictFail
+// nothing is added if there are conflicts and the error lists them. A replaced password goes to the history.
+func Apply(root *storage.Folder, entries []Entry, conflict string) (*Applied, error) {
+	existing := make(map[string]bool)
+	for _, key := range root.Keys() {
+		existing[key] = true
+	}
+
+	applied := &Applied{}
+	imported := make(map[string]*storage.Folder)
+	var conflicts []string
+	for _, e := range entries {
+		key := e.Key
+		if prev, ok := imported[key]; ok && reflect.DeepEqual(prev, e.Values) {
+			applied.Duplicates = append(applied.Duplicates, key)
+			continue
+		}
+
+		if existing[key] || imported[key] != nil {
+			switch conflict {
+			case ConflictFail:
+				conflicts = append(conflicts, key)
+				continue
+			case ConflictSkip:
+				applied.Skipped = append(applied.Skipped, key)
+				continue
+			case ConflictReplace:
+				applied.Replaced = append(applied.Replaced, key)
+			case ConflictRename:
+				renamed := key
+				for i := 2; existing[renamed] || imported[renamed] != nil; i++ {
+					renamed = fmt.Sprintf("%s (%d)", key, i)
+				}
+				applied.Renamed = append(applied.Renamed, [2]string{key, renamed})
+				key = renamed
+			default:
+				return nil, fmt.Errorf("unknown conflict policy %q, use one of %s", conflict, strings.Join(Conflicts, ", "))
+			}
+		}
+		imported[key] = e.Values
+		applied.Stored = append(applied.Stored, Entry{Key: key, Values: e.Values})
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, errors.Wrap(storage.ErrKeyExists, strings.Join(slices.Compact(conflicts), ", "))
+	}
+	for _, e := range applied.Stored {
+		if err := root.Put(e.Key, e.Values, true); err != nil {
+			return nil, err
+		}
+	}
+	return applied, nil
+}
@@ -0,0 +1,145 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/koss-null/passy/internal/kdbx"
+	"github.com/koss-null/passy/internal/otp"
+	"github.com/koss-null/passy/internal/storage"
+)
+
+// KeePass standard string fields, KeePassXC keeps the TOTP URI in otp.
+const (
+	keePassTitle    = "Title"
+	keePassUserName = "UserName"
+	keePassPassword = "Password"
+	keePassURL      = "URL"
+	keePassNotes    = "Notes"
+	keePassOTP      = "otp"
+)
+
+// ReadKeePass reads a KDBX database: groups become folders and entries become keys named by
+// their titles, the root group and the recycle bin are left out. Custom strings become fields,
+// attachments and entry history can't be imported and are reported.
+func ReadKeePass(r io.Reader, creds kdbx.Credentials) (*Result, error) {
+	db, err := kdbx.Read(r, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	history := 0
+	var walk func(g *kdbx.Group, prefix string)
+	walk = func(g *kdbx.Group, prefix string) {
+		// an entry and a group can share a name, a key keeps both values and subkeys
+		used, usedGroups := make(map[string]bool), make(map[string]bool)
+		for _, e := range g.Entries {
+			key := uniqueName(keyName(e.Get(keePassTitle)), used)
+			if prefix != "" {
+				key = prefix + "/" + key
+			}
+			entry, skipped := keePassEntry(e)
+			for _, s := range skipped {
+				res.Skipped = append(res.Skipped, key+": "+s)
+			}
+			if len(e.History) > 0 {
+				history++
+			}
+			if entry == nil {
+				res.Skipped = append(res.Skipped, key+": no values to import")
+				continue
+			}
+			res.Entries = append(res.Entries, Entry{Key: key, Values: entry})
+		}
+
+		for _, sg := range g.Groups {
+			name := uniqueName(keyName(sg.Name), usedGroups)
+			if prefix != "" {
+				name = prefix + "/" + name
+			}
+			if db.RecycleBin != "" && sg.UUID == db.RecycleBin {
+				if n := countEntries(sg); n > 0 {
+					res.Skipped = append(res.Skipped, fmt.Sprintf("%s: %d entries in the recycle bin", name, n))
+				}
+				continue
+			}
+			walk(sg, name)
+		}
+	}
+	walk(db.Root, "")
+
+	if history > 0 {
+		res.Skipped = append(res.Skipped, fmt.Sprintf("previous versions of %d entries, only the current ones are imported", history))
+	}
+	return res, nil
+}
+
+// keePassEntry maps the entry strings, it returns nil for an entry without values.
+func keePassEntry(e *kdbx.Entry) (*storage.Folder, []string) {
+	entry := &storage.Folder{}
+	var skipped []string
+	for _, s := range e.Strings {
+		switch s.Key {
+		case keePassTitle:
+		case keePassPassword:
+			entry.Pass = s.Value
+		case keePassUserName:
+			entry.Username = s.Value
+		case keePassURL:
+			entry.URL = s.Value
+		case keePassNotes:
+			entry.Notes = s.Value
+		case keePassOTP:
+			if s.Value == "" {
+				continue
+			}
+			totp, err := otp.Parse(s.Value)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("TOTP secret: %v", err))
+				continue
+			}
+			entry.OTP = totp.URI()
+		default:
+			if err := entry.SetField(s.Key, s.Value, s.Protected); err != nil {
+				skipped = append(skipped, fmt.Sprintf("field %q: %v", s.Key, err))
+			}
+		}
+	}
+	for _, name := range e.Attachments {
+		skipped = append(skipped, fmt.Sprintf("attachment %q", name))
+	}
+
+	if entry.Pass == "" && entry.Username == "" && entry.URL == "" && entry.Notes == "" && entry.OTP == "" && len(entry.Fields) == 0 {
+		return nil, skipped
+	}
+	return entry, skipped
+}
+
+// keyName makes a key part of a title or a group name, '/' separates keys so it's replaced.
+func keyName(name string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "/", "-"))
+	if name == "" {
+		return "untitled"
+	}
+	return name
+}
+
+// uniqueName numbers names repeated within a group: name, name (2), name (3).
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)", name, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+func countEntries(g *kdbx.Group) int {
+	n := len(g.Entries)
+	for _, sg := range g.Groups {
+		n += countEntries(sg)
+	}
+	return n
+}
@@ -0,0 +1,205 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/pkg/errors"
+
+	"github.com/koss-null/passy/internal/otp"
+	"github.com/koss-null/passy/internal/storage"
+)
+
+const (
+	passFileExt  = ".gpg"
+	armorHeader  = "-----BEGIN PGP"
+	passIDFile   = ".gpg-id"
+	passNotesSep = "\n"
+	otpauthURI   = "otpauth://"
+)
+
+// passUsernameKeys and passURLKey start the lines browserpass and other pass extensions read the username
+// and the URL from, they are matched case-insensitively.
+var (
+	passUsernameKeys = []string{"login:", "user:", "username:"}
+	passURLKey       = "url:"
+)
+
+// ReadKeyRing reads OpenPGP private keys, armored or binary, as exported by gpg --export-secret-keys.
+// Encrypted keys are decrypted with the passphrase returned by the callback, it's asked once.
+func ReadKeyRing(path string, passphrase func() ([]byte, error)) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the private key")
+	}
+
+	var keyring openpgp.EntityList
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armorHeader)) {
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the private key")
+	}
+
+	var pass []byte
+	private := false
+	for _, e := range keyring {
+		if e.PrivateKey == nil {
+			continue
+		}
+		private = true
+		if !encrypted(e) {
+			continue
+		}
+		if pass == nil {
+			if pass, err = passphrase(); err != nil {
+				return nil, err
+			}
+		}
+		if err := e.DecryptPrivateKeys(pass); err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt the private key")
+		}
+	}
+	if !private {
+		return nil, fmt.Errorf("%s has no private keys", path)
+	}
+	return keyring, nil
+}
+
+func encrypted(e *openpgp.Entity) bool {
+	if e.PrivateKey.Encrypted {
+		return true
+	}
+	for _, sub := range e.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadPassStore reads a pass (password-store) directory: every .gpg file is an entry keyed by
+// its path without the extension, the first line is the password. The first login:, user: or username:
+// line is the username, the first url: line is the URL and the first otpauth:// line is the TOTP secret
+// as pass-otp keeps it, the rest are notes.
+// Files which can't be decrypted with the keyring are skipped.
+func ReadPassStore(dir string, keyring openpgp.EntityList) (*Result, error) {
+	res := &Result{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			// .git, .gpg-id and other pass metadata
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if filepath.Ext(path) != passFileExt {
+			res.Skipped = append(res.Skipped, fmt.Sprintf("%s: not a .gpg file", filepath.ToSlash(rel)))
+			return nil
+		}
+
+		key := filepath.ToSlash(strings.TrimSuffix(rel, passFileExt))
+		entry, err := readPassFile(path, keyring)
+		if err != nil {
+			res.Skipped = append(res.Skipped, fmt.Sprintf("%s: %v", key, err))
+			return nil
+		}
+		res.Entries = append(res.Entries, Entry{Key: key, Values: entry})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the password store")
+	}
+	if len(res.Entries) == 0 && len(res.Skipped) == 0 {
+		if _, err := os.Stat(filepath.Join(dir, passIDFile)); err != nil {
+			return nil, fmt.Errorf("%s is not a password store", dir)
+		}
+	}
+	return res, nil
+}
+
+func readPassFile(path string, keyring openpgp.EntityList) (*storage.Folder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armorHeader)) {
+		block, err := armor.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		r = block.Body
+	}
+
+	md, err := openpgp.ReadMessage(r, keyring, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt")
+	}
+	plain, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt")
+	}
+
+	content := strings.ReplaceAll(string(plain), "\r\n", "\n")
+	pass, rest, _ := strings.Cut(content, passNotesSep)
+	return parsePassLines(pass, rest), nil
+}
+
+// parsePassLines reads the username, the URL and the TOTP secret from the lines after the password.
+func parsePassLines(pass, rest string) *storage.Folder {
+	entry := &storage.Folder{Pass: pass}
+	var notes []string
+	for _, line := range strings.Split(rest, "\n") {
+		trimmed := strings.TrimSpace(line)
+		lower := strings.ToLower(trimmed)
+		switch {
+		case entry.Username == "" && hasAnyPrefix(lower, passUsernameKeys):
+			_, entry.Username, _ = strings.Cut(trimmed, ":")
+			entry.Username = strings.TrimSpace(entry.Username)
+		case entry.URL == "" && strings.HasPrefix(lower, passURLKey):
+			entry.URL = strings.TrimSpace(trimmed[len(passURLKey):])
+		case entry.OTP == "" && strings.HasPrefix(lower, otpauthURI):
+			totp, err := otp.Parse(trimmed)
+			if err != nil {
+				// keep what can't be read as a TOTP secret
+				notes = append(notes, line)
+				continue
+			}
+			entry.OTP = totp.URI()
+		default:
+			notes = append(notes, line)
+		}
+	}
+	entry.Notes = strings.TrimRight(strings.Join(notes, "\n"), "\n")
+	return entry
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/koss-null/passy/internal/storage"
+)
+
+func TestParsePassLines(t *testing.T) {
+	const uri = "otpauth://totp/?algorithm=SHA1&digits=6&period=30&secret=JBSWY3DPEHPK3PXP"
+	tests := []struct {
+		name string
+		rest string
+		want storage.Folder
+	}{
+		{
+			name: "notes only",
+			rest: "line1\nline2\n",
+			want: storage.Folder{Notes: "line1\nline2"},
+		},
+		{
+			name: "exported by passy",
+			rest: "login: bob\nurl: https://mail.example\n" + uri + "\nPIN: 1234\nline1\n",
+			want: storage.Folder{Username: "bob", URL: "https://mail.example", OTP: uri, Notes: "PIN: 1234\nline1"},
+		},
+		{
+			name: "browserpass keys",
+			rest: "User: alice\nURL:example.com",
+			want: storage.Folder{Username: "alice", URL: "example.com"},
+		},
+		{
+			name: "only the first username and url",
+			rest: "username: a\nlogin: b\nurl: x\nurl: y",
+			want: storage.Folder{Username: "a", URL: "x", Notes: "login: b\nurl: y"},
+		},
+		{
+			name: "invalid otpauth",
+			rest: "otpauth://hotp/x?secret=JBSWY3DPEHPK3PXP&counter=1",
+			want: storage.Folder{Notes: "otpauth://hotp/x?secret=JBSWY3DPEHPK3PXP&counter=1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parsePassLines("pass", tt.rest)
+			tt.want.Pass = "pass"
+			if got.Pass != tt.want.Pass || got.Username != tt.want.Username || got.URL != tt.want.URL ||
+				got.OTP != tt.want.OTP || got.Notes != tt.want.Notes {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
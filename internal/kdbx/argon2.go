@@ -0,0 +1,249 @@
+package kdbx
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Argon2 (RFC 9106) version 1.3. golang.org/x/crypto/argon2 has no Argon2d, which is the KeePass
+// default, so both variants KDBX 4 uses are implemented here.
+const (
+	argon2d  = 0
+	argon2id = 2
+
+	argon2Version   = 0x13
+	argon2BlockSize = 128 // uint64 words, 1 KiB
+	argon2Slices    = 4
+)
+
+type argon2Block [argon2BlockSize]uint64
+
+// argon2Key derives keyLen bytes with the given mode, memory is in KiB.
+func argon2Key(mode uint32, password, salt, secret, data []byte, time, memory, lanes, keyLen uint32) []byte {
+	h0 := argon2InitHash(mode, password, salt, secret, data, time, memory, lanes, keyLen)
+
+	memory = memory / (argon2Slices * lanes) * (argon2Slices * lanes)
+	if memory < 2*argon2Slices*lanes {
+		memory = 2 * argon2Slices * lanes
+	}
+	laneLen := memory / lanes
+	segLen := laneLen / argon2Slices
+
+	b := make([]argon2Block, memory)
+	var buf [argon2BlockSize * 8]byte
+	for lane := uint32(0); lane < lanes; lane++ {
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(h0[blake2b.Size:], i)
+			binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+			argon2Hash(buf[:], h0[:])
+			for j := range b[lane*laneLen+i] {
+				b[lane*laneLen+i][j] = binary.LittleEndian.Uint64(buf[j*8:])
+			}
+		}
+	}
+
+	// segments of a slice only reference other slices, so lanes can be filled one after another
+	for pass := uint32(0); pass < time; pass++ {
+		for slice := uint32(0); slice < argon2Slices; slice++ {
+			for lane := uint32(0); lane < lanes; lane++ {
+				s := argon2Segment{
+					b: b, mode: mode, pass: pass, slice: slice, lane: lane,
+					lanes: lanes, laneLen: laneLen, segLen: segLen, memory: memory, time: time,
+				}
+				s.fill()
+			}
+		}
+	}
+
+	last := &b[memory-1]
+	for lane := uint32(0); lane < lanes-1; lane++ {
+		for i, v := range b[lane*laneLen+laneLen-1] {
+			last[i] ^= v
+		}
+	}
+	for i, v := range last {
+		binary.LittleEndian.PutUint64(buf[i*8:], v)
+	}
+	key := make([]byte, keyLen)
+	argon2Hash(key, buf[:])
+	return key
+}
+
+// argon2InitHash returns H0 with 8 spare bytes for the block and lane indexes.
+func argon2InitHash(mode uint32, password, salt, secret, data []byte, time, memory, lanes, keyLen uint32) []byte {
+	h, _ := blake2b.New512(nil)
+	var word [4]byte
+	writeWord := func(v uint32) {
+		binary.LittleEndian.PutUint32(word[:], v)
+		h.Write(word[:])
+	}
+	for _, v := range []uint32{lanes, keyLen, memory, time, argon2Version, mode} {
+		writeWord(v)
+	}
+	for _, v := range [][]byte{password, salt, secret, data} {
+		writeWord(uint32(len(v)))
+		h.Write(v)
+	}
+	return h.Sum(make([]byte, 0, blake2b.Size+8))[:blake2b.Size+8]
+}
+
+// argon2Hash is the variable length hash H' filling out.
+func argon2Hash(out, in []byte) {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(out)))
+	if len(out) <= blake2b.Size {
+		h, _ := blake2b.New(len(out), nil)
+		h.Write(size[:])
+		h.Write(in)
+		h.Sum(out[:0])
+		return
+	}
+
+	h, _ := blake2b.New512(nil)
+	h.Write(size[:])
+	h.Write(in)
+	v := h.Sum(nil)
+	n := copy(out, v[:blake2b.Size/2])
+	for len(out)-n > blake2b.Size {
+		sum := blake2b.Sum512(v)
+		v = sum[:]
+		n += copy(out[n:], v[:blake2b.Size/2])
+	}
+	h, _ = blake2b.New(len(out)-n, nil)
+	h.Write(v)
+	h.Sum(out[n:n])
+}
+
+type argon2Segment struct {
+	b                                    []argon2Block
+	mode, pass, slice, lane              uint32
+	lanes, laneLen, segLen, memory, time uint32
+
+	input, addresses argon2Block
+}
+
+// fill computes the blocks of the segment.
+func (s *argon2Segment) fill() {
+	// Argon2id takes data independent addresses in the first half of the first pass
+	independent := s.mode == argon2id && s.pass == 0 && s.slice < argon2Slices/2
+	if independent {
+		s.input[0], s.input[1], s.input[2] = uint64(s.pass), uint64(s.lane), uint64(s.slice)
+		s.input[3], s.input[4], s.input[5] = uint64(s.memory), uint64(s.time), uint64(s.mode)
+	}
+
+	start := uint32(0)
+	if s.pass == 0 && s.slice == 0 {
+		// the first two blocks are made from H0
+		start = 2
+		if independent {
+			s.nextAddresses()
+		}
+	}
+
+	offset := s.lane*s.laneLen + s.slice*s.segLen + start
+	for index := start; index < s.segLen; index, offset = index+1, offset+1 {
+		prev := offset - 1
+		if s.slice == 0 && index == 0 {
+			prev += s.laneLen
+		}
+
+		var rand uint64
+		if independent {
+			if index%argon2BlockSize == 0 {
+				s.nextAddresses()
+			}
+			rand = s.addresses[index%argon2BlockSize]
+		} else {
+			rand = s.b[prev][0]
+		}
+
+		// before version 1.3 blocks were overwritten, they are xored now; the first pass xors zero blocks
+		argon2Compress(&s.b[offset], &s.b[prev], &s.b[s.refIndex(rand, index)], true)
+	}
+}
+
+func (s *argon2Segment) nextAddresses() {
+	var zero argon2Block
+	s.input[6]++
+	argon2Compress(&s.addresses, &zero, &s.input, false)
+	argon2Compress(&s.addresses, &zero, &s.addresses, false)
+}
+
+// refIndex maps the pseudo-random value to the index of the referenced block.
+func (s *argon2Segment) refIndex(rand uint64, index uint32) uint32 {
+	refLane := uint32(rand>>32) % s.lanes
+	if s.pass == 0 && s.slice == 0 {
+		refLane = s.lane
+	}
+	sameLane := refLane == s.lane
+
+	// the area is all finished blocks of the lane, or of the finished slices for other lanes
+	var area, start uint32
+	if s.pass == 0 {
+		area = s.slice * s.segLen
+	} else {
+		area = s.laneLen - s.segLen
+		start = (s.slice + 1) % argon2Slices * s.segLen
+	}
+	if sameLane {
+		area += index - 1
+	} else if index == 0 {
+		area--
+	}
+
+	x := rand & 0xFFFFFFFF
+	x = x * x >> 32
+	x = uint64(area) * x >> 32
+	rel := uint64(area) - 1 - x
+	return refLane*s.laneLen + uint32((uint64(start)+rel)%uint64(s.laneLen))
+}
+
+// argon2Compress is the compression function G, with xor the result is xored into out.
+func argon2Compress(out, x, y *argon2Block, xor bool) {
+	var r, z argon2Block
+	for i := range r {
+		r[i] = x[i] ^ y[i]
+	}
+	z = r
+	for i := 0; i < 8; i++ {
+		// rows
+		blamka(&z, 16*i, 16*i+1, 16*i+2, 16*i+3, 16*i+4, 16*i+5, 16*i+6, 16*i+7,
+			16*i+8, 16*i+9, 16*i+10, 16*i+11, 16*i+12, 16*i+13, 16*i+14, 16*i+15)
+	}
+	for i := 0; i < 8; i++ {
+		// columns
+		blamka(&z, 2*i, 2*i+1, 2*i+16, 2*i+17, 2*i+32, 2*i+33, 2*i+48, 2*i+49,
+			2*i+64, 2*i+65, 2*i+80, 2*i+81, 2*i+96, 2*i+97, 2*i+112, 2*i+113)
+	}
+	for i := range z {
+		if xor {
+			out[i] ^= r[i] ^ z[i]
+		} else {
+			out[i] = r[i] ^ z[i]
+		}
+	}
+}
+
+// blamka is the BLAKE2b round with multiplications over 16 words of the block.
+func blamka(b *argon2Block, i ...int) {
+	mix := func(a, bb, c, d int) {
+		b[i[a]] += b[i[bb]] + 2*uint64(uint32(b[i[a]]))*uint64(uint32(b[i[bb]]))
+		b[i[d]] = bits.RotateLeft64(b[i[d]]^b[i[a]], -32)
+		b[i[c]] += b[i[d]] + 2*uint64(uint32(b[i[c]]))*uint64(uint32(b[i[d]]))
+		b[i[bb]] = bits.RotateLeft64(b[i[bb]]^b[i[c]], -24)
+		b[i[a]] += b[i[bb]] + 2*uint64(uint32(b[i[a]]))*uint64(uint32(b[i[bb]]))
+		b[i[d]] = bits.RotateLeft64(b[i[d]]^b[i[a]], -16)
+		b[i[c]] += b[i[d]] + 2*uint64(uint32(b[i[c]]))*uint64(uint32(b[i[d]]))
+		b[i[bb]] = bits.RotateLeft64(b[i[bb]]^b[i[c]], -63)
+	}
+	mix(0, 4, 8, 12)
+	mix(1, 5, 9, 13)
+	mix(2, 6, 10, 14)
+	mix(3, 7, 11, 15)
+	mix(0, 5, 10, 15)
+	mix(1, 6, 11, 12)
+	mix(2, 7, 8, 13)
+	mix(3, 4, 9, 14)
+}
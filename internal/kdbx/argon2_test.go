@@ -0,0 +1,59 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// TestArgon2RFC9106 checks the test vectors of RFC 9106 sections 5.1 (Argon2d) and 5.3 (Argon2id).
+func TestArgon2RFC9106(t *testing.T) {
+	var (
+		password = bytes.Repeat([]byte{0x01}, 32)
+		salt     = bytes.Repeat([]byte{0x02}, 16)
+		secret   = bytes.Repeat([]byte{0x03}, 8)
+		data     = bytes.Repeat([]byte{0x04}, 12)
+	)
+	tests := []struct {
+		name string
+		mode uint32
+		tag  string
+	}{
+		{"argon2d", argon2d, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"},
+		{"argon2id", argon2id, "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := argon2Key(tt.mode, password, salt, secret, data, 3, 32, 4, 32)
+			if hex.EncodeToString(got) != tt.tag {
+				t.Errorf("got %x, want %s", got, tt.tag)
+			}
+		})
+	}
+}
+
+// TestArgon2idMatchesXCrypto compares Argon2id with golang.org/x/crypto/argon2, which has no secret and data inputs.
+func TestArgon2idMatchesXCrypto(t *testing.T) {
+	tests := []struct {
+		time, memory, lanes, keyLen uint32
+	}{
+		{1, 8, 1, 32},
+		{2, 64, 1, 32},
+		{3, 256, 4, 64},
+		{1, 1024, 2, 16},
+		// memory which is not a multiple of 4*lanes is rounded down
+		{2, 100, 3, 32},
+		// keys longer than 64 bytes use the variable-length hash
+		{4, 65, 8, 100},
+	}
+	for _, tt := range tests {
+		password, salt := []byte("password"), []byte("somesaltsomesalt")
+		got := argon2Key(argon2id, password, salt, nil, nil, tt.time, tt.memory, tt.lanes, tt.keyLen)
+		want := argon2.IDKey(password, salt, tt.time, tt.memory, uint8(tt.lanes), tt.keyLen)
+		if !bytes.Equal(got, want) {
+			t.Errorf("t=%d m=%d p=%d len=%d: got %x, want %x", tt.time, tt.memory, tt.lanes, tt.keyLen, got, want)
+		}
+	}
+}
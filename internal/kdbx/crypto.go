@@ -0,0 +1,220 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20/salsa"
+	"golang.org/x/crypto/twofish"
+)
+
+// Cipher and KDF UUIDs.
+var (
+	cipherAES256   = mustHex("31c1f2e6bf714350be5805216afc5aff")
+	cipherTwofish  = mustHex("ad68f29f576f4bb9a36ad47af965346c")
+	cipherChaCha20 = mustHex("d6038a2b8b6f4cb5a524339a31dbb59a")
+
+	kdfAES      = mustHex("c9d9f39a628a4460bf740d08c18a4fea")
+	kdfAESKDBX4 = mustHex("7c02bb8279a74ac0927d114a00648238")
+	kdfArgon2d  = mustHex("ef636ddf8c29444b91f7a9a403e30a0c")
+	kdfArgon2id = mustHex("9e298b1956db4773b23dfc3ec6f0a1e6")
+)
+
+// Inner random stream ids protecting values in the XML.
+const (
+	streamNone     = 0
+	streamSalsa20  = 2
+	streamChaCha20 = 3
+)
+
+var salsa20Nonce = []byte{0xE8, 0x30, 0x09, 0x4B, 0x97, 0x20, 0x5D, 0x2A}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// decrypt decrypts the payload with the cipher from the header.
+func decrypt(cipherID, key, iv, data []byte) ([]byte, error) {
+	var block cipher.Block
+	var err error
+	switch {
+	case bytes.Equal(cipherID, cipherAES256):
+		block, err = aes.NewCipher(key)
+	case bytes.Equal(cipherID, cipherTwofish):
+		block, err = twofish.NewCipher(key)
+	case bytes.Equal(cipherID, cipherChaCha20):
+		c, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, err
+		}
+		plain := make([]byte, len(data))
+		c.XORKeyStream(plain, data)
+		return plain, nil
+	default:
+		return nil, fmt.Errorf("unsupported cipher %x", cipherID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, errors.New("corrupted payload")
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	// PKCS #7 padding, broken padding means a wrong key
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > block.BlockSize() || !bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, ErrInvalidCredentials
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+// aesKDF encrypts the key with AES-256 in ECB mode the given number of rounds.
+func aesKDF(key, seed []byte, rounds uint64) ([]byte, error) {
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid AES-KDF seed")
+	}
+	k := append([]byte{}, key...)
+	for i := uint64(0); i < rounds; i++ {
+		block.Encrypt(k[:16], k[:16])
+		block.Encrypt(k[16:], k[16:])
+	}
+	sum := sha256.Sum256(k)
+	return sum[:], nil
+}
+
+// deriveKey transforms the composite key with the KDF of a KDBX 4 header.
+func deriveKey(params variantDictionary, composite []byte) ([]byte, error) {
+	uuid := params.bytes("$UUID")
+	switch {
+	case bytes.Equal(uuid, kdfAES), bytes.Equal(uuid, kdfAESKDBX4):
+		return aesKDF(composite, params.bytes("S"), params.uint64("R"))
+	case bytes.Equal(uuid, kdfArgon2d), bytes.Equal(uuid, kdfArgon2id):
+		mode := uint32(argon2d)
+		if bytes.Equal(uuid, kdfArgon2id) {
+			mode = argon2id
+		}
+		if v := params.uint32("V"); v != argon2Version {
+			return nil, fmt.Errorf("unsupported Argon2 version %#x", v)
+		}
+		iterations, memory, lanes := params.uint64("I"), params.uint64("M")/1024, params.uint32("P")
+		if iterations == 0 || iterations > 1<<32-1 || memory > 1<<32-1 || lanes == 0 || lanes > 1<<24-1 {
+			return nil, errors.New("invalid Argon2 parameters")
+		}
+		return argon2Key(mode, composite, params.bytes("S"), params.bytes("K"), params.bytes("A"),
+			uint32(iterations), uint32(memory), lanes, 32), nil
+	default:
+		return nil, fmt.Errorf("unsupported KDF %x", uuid)
+	}
+}
+
+// innerStream decrypts protected values of the XML, they share one key stream in the document order.
+type innerStream interface {
+	XORKeyStream(dst, src []byte)
+}
+
+func newInnerStream(id uint32, key []byte) (innerStream, error) {
+	switch id {
+	case streamNone:
+		return nil, nil
+	case streamSalsa20:
+		s := &salsa20Stream{}
+		s.key = sha256.Sum256(key)
+		copy(s.counter[:], salsa20Nonce)
+		return s, nil
+	case streamChaCha20:
+		sum := sha512.Sum512(key)
+		return chacha20.NewUnauthenticatedCipher(sum[:32], sum[32:44])
+	default:
+		return nil, fmt.Errorf("unsupported inner random stream %d", id)
+	}
+}
+
+// salsa20Stream is Salsa20 with the key stream kept between calls.
+type salsa20Stream struct {
+	key     [32]byte
+	counter [16]byte
+	block   [64]byte
+	used    int
+}
+
+func (s *salsa20Stream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.used == 0 || s.used == len(s.block) {
+			var zero [64]byte
+			salsa.XORKeyStream(s.block[:], zero[:], &s.counter, &s.key)
+			binary.LittleEndian.PutUint64(s.counter[8:], binary.LittleEndian.Uint64(s.counter[8:])+1)
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.block[s.used]
+		s.used++
+	}
+}
+
+// variantDictionary is the KDBX 4 key-value map, values are kept raw.
+type variantDictionary map[string][]byte
+
+func readVariantDictionary(data []byte) (variantDictionary, error) {
+	if len(data) < 2 || data[1] != 1 {
+		return nil, errors.New("unsupported variant dictionary version")
+	}
+	data = data[2:]
+
+	res := variantDictionary{}
+	for {
+		if len(data) < 1 {
+			return nil, errors.New("truncated variant dictionary")
+		}
+		if data[0] == 0 {
+			return res, nil
+		}
+		if len(data) < 5 {
+			return nil, errors.New("truncated variant dictionary")
+		}
+		keyLen := int(int32(binary.LittleEndian.Uint32(data[1:])))
+		if keyLen < 0 || len(data) < 5+keyLen+4 {
+			return nil, errors.New("truncated variant dictionary")
+		}
+		key := string(data[5 : 5+keyLen])
+		data = data[5+keyLen:]
+		valueLen := int(int32(binary.LittleEndian.Uint32(data)))
+		if valueLen < 0 || len(data) < 4+valueLen {
+			return nil, errors.New("truncated variant dictionary")
+		}
+		res[key] = data[4 : 4+valueLen]
+		data = data[4+valueLen:]
+	}
+}
+
+func (d variantDictionary) bytes(key string) []byte {
+	return d[key]
+}
+
+func (d variantDictionary) uint32(key string) uint32 {
+	if v := d[key]; len(v) == 4 {
+		return binary.LittleEndian.Uint32(v)
+	}
+	return 0
+}
+
+func (d variantDictionary) uint64(key string) uint64 {
+	if v := d[key]; len(v) == 8 {
+		return binary.LittleEndian.Uint64(v)
+	}
+	return 0
+}
@@ -0,0 +1,36 @@
+package kdbx
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadVariantDictionary(t *testing.T) {
+	data := []byte{
+		0x00, 0x01, // version 1.0
+		0x04, 0x01, 0x00, 0x00, 0x00, 'I', 0x08, 0x00, 0x00, 0x00, 0x02, 0, 0, 0, 0, 0, 0, 0, // UInt64 I = 2
+		0x0c, 0x01, 0x00, 0x00, 0x00, 'P', 0x04, 0x00, 0x00, 0x00, 0x04, 0, 0, 0, // UInt32 P = 4
+		0x42, 0x01, 0x00, 0x00, 0x00, 'S', 0x02, 0x00, 0x00, 0x00, 0xab, 0xcd, // ByteArray S
+		0x00,
+	}
+	d, err := readVariantDictionary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.uint64("I") != 2 || d.uint32("P") != 4 || !bytes.Equal(d.bytes("S"), []byte{0xab, 0xcd}) {
+		t.Errorf("unexpected dictionary %v", d)
+	}
+	// a value of another size is not read as a number
+	if d.uint32("I") != 0 {
+		t.Errorf("uint32 of an 8 byte value: %d", d.uint32("I"))
+	}
+
+	for i := range len(data) - 1 {
+		if _, err := readVariantDictionary(data[:i]); err == nil {
+			t.Errorf("a dictionary truncated to %d bytes was read", i)
+		}
+	}
+	if _, err := readVariantDictionary([]byte{0x00, 0x02, 0x00}); err == nil {
+		t.Error("an unsupported version was read")
+	}
+}
@@ -0,0 +1,311 @@
+// Package kdbx reads KeePass KDBX 3.1 and KDBX 4 databases.
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	signature1 = 0x9AA2D903
+	signature2 = 0xB54BFB67
+	// signature2 of KeePass 1 .kdb files
+	signature2KDB = 0xB54BFB65
+)
+
+// Header field ids.
+const (
+	headerEnd                 = 0
+	headerCipherID            = 2
+	headerCompression         = 3
+	headerMasterSeed          = 4
+	headerTransformSeed       = 5
+	headerTransformRounds     = 6
+	headerEncryptionIV        = 7
+	headerProtectedStreamKey  = 8
+	headerStreamStartBytes    = 9
+	headerInnerRandomStreamID = 10
+	headerKdfParameters       = 11
+)
+
+const compressionGzip = 1
+
+// ErrInvalidCredentials is returned when the password or the key file doesn't match the database.
+var ErrInvalidCredentials = errors.New("invalid master password or key file")
+
+// Credentials unlock a database, nil fields are not used.
+type Credentials struct {
+	Password []byte
+	// KeyFile is the content of the key file.
+	KeyFile []byte
+}
+
+// Database is the decrypted content of a KDBX file.
+type Database struct {
+	Root *Group
+	// RecycleBin is the UUID of the recycle bin group, empty if it's disabled.
+	RecycleBin string
+}
+
+// Group is a KeePass group, UUIDs are base64 as stored in the XML.
+type Group struct {
+	UUID    string
+	Name    string
+	Groups  []*Group
+	Entries []*Entry
+}
+
+// Entry is a KeePass entry.
+type Entry struct {
+	UUID string
+	// Strings are the standard (Title, UserName, Password, URL, Notes) and custom fields in the file order.
+	Strings []String
+	// Attachments are the names of attached files.
+	Attachments []string
+	// History are previous versions of the entry.
+	History []*Entry
+}
+
+// String is a named field of an entry.
+type String struct {
+	Key       string
+	Value     string
+	Protected bool
+}
+
+// Get returns the value of the field.
+func (e *Entry) Get(key string) string {
+	for _, s := range e.Strings {
+		if s.Key == key {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+type header struct {
+	major, minor        uint16
+	cipherID            []byte
+	compression         uint32
+	masterSeed          []byte
+	transformSeed       []byte
+	transformRounds     uint64
+	iv                  []byte
+	protectedStreamKey  []byte
+	streamStartBytes    []byte
+	innerRandomStreamID uint32
+	kdf                 variantDictionary
+}
+
+// Read decrypts the database.
+func Read(r io.Reader, creds Credentials) (*Database, error) {
+	if creds.Password == nil && creds.KeyFile == nil {
+		return nil, errors.New("a master password or a key file is required")
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	h, headerLen, err := readHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	composite, err := creds.compositeKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	var stream innerStream
+	if h.major == 3 {
+		content, stream, err = readBody3(h, data[headerLen:], composite)
+	} else {
+		content, stream, err = readBody4(h, data[:headerLen], data[headerLen:], composite)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseXML(content, stream)
+}
+
+// readHeader parses the header and returns it with its length in bytes.
+func readHeader(data []byte) (*header, int, error) {
+	if len(data) < 12 || binary.LittleEndian.Uint32(data) != signature1 {
+		return nil, 0, errors.New("not a KeePass database")
+	}
+	switch binary.LittleEndian.Uint32(data[4:]) {
+	case signature2:
+	case signature2KDB:
+		return nil, 0, errors.New("KeePass 1 databases are not supported, save it as KDBX with KeePass 2 or KeePassXC")
+	default:
+		return nil, 0, errors.New("not a KeePass database")
+	}
+
+	h := &header{
+		minor: binary.LittleEndian.Uint16(data[8:]),
+		major: binary.LittleEndian.Uint16(data[10:]),
+	}
+	if h.major != 3 && h.major != 4 {
+		return nil, 0, fmt.Errorf("unsupported KDBX version %d.%d", h.major, h.minor)
+	}
+
+	pos := 12
+	for {
+		sizeLen := 2
+		if h.major == 4 {
+			sizeLen = 4
+		}
+		if len(data) < pos+1+sizeLen {
+			return nil, 0, errors.New("truncated header")
+		}
+		id := data[pos]
+		var size int
+		if sizeLen == 2 {
+			size = int(binary.LittleEndian.Uint16(data[pos+1:]))
+		} else {
+			size = int(binary.LittleEndian.Uint32(data[pos+1:]))
+		}
+		pos += 1 + sizeLen
+		if size < 0 || len(data) < pos+size {
+			return nil, 0, errors.New("truncated header")
+		}
+		value := data[pos : pos+size]
+		pos += size
+
+		switch id {
+		case headerEnd:
+			return h, pos, h.validate()
+		case headerCipherID:
+			h.cipherID = value
+		case headerCompression:
+			if len(value) != 4 {
+				return nil, 0, errors.New("invalid compression flags")
+			}
+			h.compression = binary.LittleEndian.Uint32(value)
+		case headerMasterSeed:
+			h.masterSeed = value
+		case headerTransformSeed:
+			h.transformSeed = value
+		case headerTransformRounds:
+			if len(value) != 8 {
+				return nil, 0, errors.New("invalid transform rounds")
+			}
+			h.transformRounds = binary.LittleEndian.Uint64(value)
+		case headerEncryptionIV:
+			h.iv = value
+		case headerProtectedStreamKey:
+			h.protectedStreamKey = value
+		case headerStreamStartBytes:
+			h.streamStartBytes = value
+		case headerInnerRandomStreamID:
+			if len(value) != 4 {
+				return nil, 0, errors.New("invalid inner random stream id")
+			}
+			h.innerRandomStreamID = binary.LittleEndian.Uint32(value)
+		case headerKdfParameters:
+			kdf, err := readVariantDictionary(value)
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "invalid KDF parameters")
+			}
+			h.kdf = kdf
+		}
+	}
+}
+
+func (h *header) validate() error {
+	if len(h.cipherID) == 0 || len(h.masterSeed) == 0 || len(h.iv) == 0 {
+		return errors.New("the header misses required fields")
+	}
+	if h.major == 3 && (len(h.transformSeed) == 0 || len(h.streamStartBytes) == 0) {
+		return errors.New("the header misses required fields")
+	}
+	if h.major == 4 && h.kdf == nil {
+		return errors.New("the header misses KDF parameters")
+	}
+	return nil
+}
+
+// masterKey derives the key of the payload cipher from the transformed composite key.
+func (h *header) masterKey(transformed []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, h.masterSeed...), transformed...))
+	return sum[:]
+}
+
+// readBody3 decrypts the KDBX 3.1 payload: the encrypted hashed block stream.
+func readBody3(h *header, body, composite []byte) ([]byte, innerStream, error) {
+	transformed, err := aesKDF(composite, h.transformSeed, h.transformRounds)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := decrypt(h.cipherID, h.masterKey(transformed), h.iv, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(plain) < len(h.streamStartBytes) || !bytes.Equal(plain[:len(h.streamStartBytes)], h.streamStartBytes) {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	content, err := readHashedBlocks(plain[len(h.streamStartBytes):])
+	if err != nil {
+		return nil, nil, err
+	}
+	if content, err = decompress(h.compression, content); err != nil {
+		return nil, nil, err
+	}
+	stream, err := newInnerStream(h.innerRandomStreamID, h.protectedStreamKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, stream, nil
+}
+
+// readHashedBlocks joins blocks of index, SHA-256, size and data until an empty block.
+func readHashedBlocks(data []byte) ([]byte, error) {
+	var res []byte
+	for {
+		if len(data) < 40 {
+			return nil, errors.New("truncated block stream")
+		}
+		hash, size := data[4:36], int(binary.LittleEndian.Uint32(data[36:]))
+		data = data[40:]
+		if size == 0 {
+			return res, nil
+		}
+		if size < 0 || len(data) < size {
+			return nil, errors.New("truncated block stream")
+		}
+		if sum := sha256.Sum256(data[:size]); !bytes.Equal(sum[:], hash) {
+			return nil, errors.New("corrupted block stream")
+		}
+		res = append(res, data[:size]...)
+		data = data[size:]
+	}
+}
+
+func decompress(compression uint32, data []byte) ([]byte, error) {
+	switch compression {
+	case 0:
+		return data, nil
+	case compressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decompress the database")
+		}
+		// some writers leave padding after the stream, KeePass ignores it
+		r.Multistream(false)
+		res, err := io.ReadAll(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decompress the database")
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", compression)
+	}
+}
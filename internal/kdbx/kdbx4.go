@@ -0,0 +1,127 @@
+package kdbx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Inner header field ids of KDBX 4.
+const (
+	innerHeaderEnd       = 0
+	innerHeaderStreamID  = 1
+	innerHeaderStreamKey = 2
+	innerHeaderBinary    = 3
+)
+
+// readBody4 checks the header and decrypts the KDBX 4 payload: the HMAC block stream
+// with the inner header in front of the XML.
+func readBody4(h *header, head, body, composite []byte) ([]byte, innerStream, error) {
+	if len(body) < 64 {
+		return nil, nil, errors.New("truncated header")
+	}
+	if sum := sha256.Sum256(head); !hmac.Equal(sum[:], body[:32]) {
+		return nil, nil, errors.New("corrupted header")
+	}
+
+	transformed, err := deriveKey(h.kdf, composite)
+	if err != nil {
+		return nil, nil, err
+	}
+	hmacKey := sha512.Sum512(append(append(append([]byte{}, h.masterSeed...), transformed...), 1))
+	// the header is authenticated with the key of the last possible block index
+	if !hmac.Equal(hmacSum(blockKey(hmacKey[:], ^uint64(0)), head), body[32:64]) {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	encrypted, err := readHMACBlocks(hmacKey[:], body[64:])
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := decrypt(h.cipherID, h.masterKey(transformed), h.iv, encrypted)
+	if err != nil {
+		return nil, nil, err
+	}
+	if plain, err = decompress(h.compression, plain); err != nil {
+		return nil, nil, err
+	}
+	return readInnerHeader(plain)
+}
+
+// readHMACBlocks joins blocks of HMAC-SHA256, size and data until an empty block.
+func readHMACBlocks(key, data []byte) ([]byte, error) {
+	var res []byte
+	for index := uint64(0); ; index++ {
+		if len(data) < 36 {
+			return nil, errors.New("truncated block stream")
+		}
+		mac, size := data[:32], int(int32(binary.LittleEndian.Uint32(data[32:])))
+		if size < 0 || len(data) < 36+size {
+			return nil, errors.New("truncated block stream")
+		}
+		var idx [8]byte
+		binary.LittleEndian.PutUint64(idx[:], index)
+		if !hmac.Equal(hmacSum(blockKey(key, index), idx[:], data[32:36+size]), mac) {
+			return nil, errors.New("corrupted block stream")
+		}
+		if size == 0 {
+			return res, nil
+		}
+		res = append(res, data[36:36+size]...)
+		data = data[36+size:]
+	}
+}
+
+// blockKey returns the HMAC key of the block index.
+func blockKey(key []byte, index uint64) []byte {
+	var idx [8]byte
+	binary.LittleEndian.PutUint64(idx[:], index)
+	sum := sha512.Sum512(append(idx[:], key...))
+	return sum[:]
+}
+
+func hmacSum(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// readInnerHeader reads the protected values stream from the inner header and returns the XML after it.
+func readInnerHeader(data []byte) ([]byte, innerStream, error) {
+	var (
+		id  uint32
+		key []byte
+	)
+	for {
+		if len(data) < 5 {
+			return nil, nil, errors.New("truncated inner header")
+		}
+		field, size := data[0], int(int32(binary.LittleEndian.Uint32(data[1:])))
+		if size < 0 || len(data) < 5+size {
+			return nil, nil, errors.New("truncated inner header")
+		}
+		value := data[5 : 5+size]
+		data = data[5+size:]
+
+		switch field {
+		case innerHeaderEnd:
+			stream, err := newInnerStream(id, key)
+			return data, stream, err
+		case innerHeaderStreamID:
+			if len(value) != 4 {
+				return nil, nil, fmt.Errorf("invalid inner random stream id")
+			}
+			id = binary.LittleEndian.Uint32(value)
+		case innerHeaderStreamKey:
+			key = value
+		case innerHeaderBinary:
+			// attachments are referenced by entries and reported there
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package kdbx
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fixtures in testdata are written by gokeepasslib, all of them hold the same entries:
+//
+//	Passwords/wifi      p@ss
+//	Passwords/Web/mail  s3cret, bob, https://mail.example, notes, protected PIN and a previous version
+//
+// The password is "passy", kdbx4-keyfile.kdbx is opened with kdbx4-keyfile.keyx only.
+const fixturePassword = "passy"
+
+func readFixture(t *testing.T, name string, creds Credentials) (*Database, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return Read(f, creds)
+}
+
+func readKeyFile(t *testing.T) []byte {
+	t.Helper()
+	key, err := os.ReadFile(filepath.Join("testdata", "kdbx4-keyfile.keyx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		file    string
+		keyFile bool
+	}{
+		// KDBX 3.1, AES-256, AES-KDF, Salsa20 protected values
+		{file: "kdbx3-aes.kdbx"},
+		// KDBX 4, AES-256, AES-KDF, ChaCha20 protected values
+		{file: "kdbx4-aeskdf.kdbx"},
+		// KDBX 4, ChaCha20, Argon2d
+		{file: "kdbx4-argon2d.kdbx"},
+		{file: "kdbx4-keyfile.kdbx", keyFile: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			creds := Credentials{Password: []byte(fixturePassword)}
+			if tt.keyFile {
+				creds = Credentials{KeyFile: readKeyFile(t)}
+			}
+			db, err := readFixture(t, tt.file, creds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFixture(t, db)
+		})
+	}
+}
+
+func checkFixture(t *testing.T, db *Database) {
+	t.Helper()
+	root := db.Root
+	if root == nil || root.Name != "Passwords" {
+		t.Fatalf("root group: %+v", root)
+	}
+	if len(root.Entries) != 1 || len(root.Groups) != 1 {
+		t.Fatalf("root has %d entries and %d groups, want 1 and 1", len(root.Entries), len(root.Groups))
+	}
+	if wifi := root.Entries[0]; wifi.Get("Title") != "wifi" || wifi.Get("Password") != "p@ss" {
+		t.Errorf("wifi entry: %+v", wifi.Strings)
+	}
+
+	web := root.Groups[0]
+	if web.Name != "Web" || len(web.Entries) != 1 {
+		t.Fatalf("Web group: %+v", web)
+	}
+	mail := web.Entries[0]
+	want := []String{
+		{Key: "Title", Value: "mail"},
+		{Key: "UserName", Value: "bob"},
+		{Key: "Password", Value: "s3cret", Protected: true},
+		{Key: "URL", Value: "https://mail.example"},
+		{Key: "Notes", Value: "line1\nline2"},
+		{Key: "PIN", Value: "1234", Protected: true},
+	}
+	for _, w := range want {
+		var got *String
+		for i := range mail.Strings {
+			if mail.Strings[i].Key == w.Key {
+				got = &mail.Strings[i]
+			}
+		}
+		if got == nil || *got != w {
+			t.Errorf("mail %s: got %+v, want %+v", w.Key, got, w)
+		}
+	}
+	if len(mail.History) != 1 || mail.History[0].Get("Password") != "old" {
+		t.Errorf("mail history: %+v", mail.History)
+	}
+}
+
+func TestReadInvalidCredentials(t *testing.T) {
+	tests := []struct {
+		file  string
+		creds Credentials
+	}{
+		{"kdbx3-aes.kdbx", Credentials{Password: []byte("wrong")}},
+		{"kdbx4-aeskdf.kdbx", Credentials{Password: []byte("wrong")}},
+		{"kdbx4-argon2d.kdbx", Credentials{Password: []byte("wrong")}},
+		{"kdbx4-argon2d.kdbx", Credentials{Password: []byte(fixturePassword), KeyFile: []byte("key")}},
+		{"kdbx4-keyfile.kdbx", Credentials{Password: []byte(fixturePassword)}},
+	}
+	for _, tt := range tests {
+		if _, err := readFixture(t, tt.file, tt.creds); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: got %v, want %v", tt.file, err, ErrInvalidCredentials)
+		}
+	}
+
+	key := readKeyFile(t)
+	if _, err := readFixture(t, "kdbx4-keyfile.kdbx", Credentials{Password: []byte(fixturePassword), KeyFile: key}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("key file with a password: got %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestReadCorrupted(t *testing.T) {
+	for _, name := range []string{"kdbx3-aes.kdbx", "kdbx4-argon2d.kdbx"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the last bytes are in the encrypted payload
+		data[len(data)-40] ^= 1
+		db, err := Read(bytes.NewReader(data), Credentials{Password: []byte(fixturePassword)})
+		if err == nil {
+			t.Errorf("%s: a corrupted database was read: %+v", name, db)
+		}
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("not a database")), Credentials{Password: []byte("x")}); err == nil {
+		t.Error("garbage was read as a database")
+	}
+}
+
+func TestKeyFileKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{0xab}, 32)
+	hexKey := bytes.Repeat([]byte("ab"), 32)
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"32 bytes", raw, raw},
+		{"64 hex digits", hexKey, raw},
+		{"xml v2", readKeyFile(t), mustHex("b842f217b5152fa3d55103346e5952bac271a7bdd5adb27207c996747a682f02")},
+	}
+	for _, tt := range tests {
+		got, err := keyFileKey(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got %x, want %x", tt.name, got, tt.want)
+		}
+	}
+
+	invalid := []byte(`<KeyFile><Meta><Version>2.0</Version></Meta><Key><Data Hash="00000000">` +
+		`00112233 44556677 8899AABB CCDDEEFF 00112233 44556677 8899AABB CCDDEEFF</Data></Key></KeyFile>`)
+	if _, err := keyFileKey(invalid); err == nil {
+		t.Error("a key file with a wrong hash was accepted")
+	}
+}
@@ -0,0 +1,87 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// compositeKey hashes the password and the key file the way KeePass combines them.
+func (c Credentials) compositeKey() ([]byte, error) {
+	h := sha256.New()
+	if c.Password != nil {
+		sum := sha256.Sum256(c.Password)
+		h.Write(sum[:])
+	}
+	if c.KeyFile != nil {
+		key, err := keyFileKey(c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(key)
+	}
+	return h.Sum(nil), nil
+}
+
+// keyFile is the XML key file of KeePass 2.
+type keyFile struct {
+	Version string `xml:"Meta>Version"`
+	Data    struct {
+		Hash  string `xml:"Hash,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"Key>Data"`
+}
+
+// keyFileKey returns the key of the key file: XML versions 1.0 and 2.0, 32 raw bytes, 64 hex
+// characters, or the SHA-256 of any other file.
+func keyFileKey(data []byte) ([]byte, error) {
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("<?xml")) || bytes.HasPrefix(trimmed, []byte("<KeyFile")) {
+		var kf keyFile
+		if err := xml.Unmarshal(data, &kf); err == nil && kf.Data.Value != "" {
+			return xmlKeyFileKey(kf)
+		}
+	}
+
+	switch {
+	case len(data) == 32:
+		return data, nil
+	case len(data) == 64:
+		if key, err := hex.DecodeString(string(data)); err == nil {
+			return key, nil
+		}
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+func xmlKeyFileKey(kf keyFile) ([]byte, error) {
+	value := strings.Join(strings.Fields(kf.Data.Value), "")
+	switch {
+	case strings.HasPrefix(kf.Version, "1."):
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid key file")
+		}
+		return key, nil
+	case strings.HasPrefix(kf.Version, "2."):
+		key, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid key file")
+		}
+		if kf.Data.Hash != "" {
+			sum := sha256.Sum256(key)
+			if !strings.EqualFold(hex.EncodeToString(sum[:4]), kf.Data.Hash) {
+				return nil, errors.New("the key file is corrupted, its hash doesn't match")
+			}
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key file version %q", kf.Version)
+	}
+}
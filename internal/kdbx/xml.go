@@ -0,0 +1,200 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// xmlReader walks the database XML token by token, protected values share the inner stream
+// in the document order.
+type xmlReader struct {
+	dec    *xml.Decoder
+	stream innerStream
+}
+
+func parseXML(content []byte, stream innerStream) (*Database, error) {
+	x := &xmlReader{dec: xml.NewDecoder(bytes.NewReader(content)), stream: stream}
+	db := &Database{}
+	recycleBinEnabled := true
+
+	err := x.children(func(start xml.StartElement) error {
+		if start.Name.Local != "KeePassFile" {
+			return x.dec.Skip()
+		}
+		return x.children(func(start xml.StartElement) error {
+			switch start.Name.Local {
+			case "Meta":
+				return x.children(func(start xml.StartElement) error {
+					switch start.Name.Local {
+					case "RecycleBinEnabled":
+						v, err := x.text(start)
+						recycleBinEnabled = !strings.EqualFold(v, "false")
+						return err
+					case "RecycleBinUUID":
+						v, err := x.text(start)
+						db.RecycleBin = v
+						return err
+					}
+					return x.dec.Skip()
+				})
+			case "Root":
+				return x.children(func(start xml.StartElement) error {
+					if start.Name.Local != "Group" {
+						return x.dec.Skip()
+					}
+					g, err := x.group()
+					db.Root = g
+					return err
+				})
+			}
+			return x.dec.Skip()
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the database")
+	}
+	if db.Root == nil {
+		return nil, errors.New("the database has no root group")
+	}
+	if !recycleBinEnabled {
+		db.RecycleBin = ""
+	}
+	return db, nil
+}
+
+// children calls fn for every child element until the end of the current one.
+// fn must consume the whole child element.
+func (x *xmlReader) children(fn func(start xml.StartElement) error) error {
+	for {
+		tok, err := x.dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if err := fn(t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (x *xmlReader) text(start xml.StartElement) (string, error) {
+	var s string
+	err := x.dec.DecodeElement(&s, &start)
+	return s, err
+}
+
+func (x *xmlReader) group() (*Group, error) {
+	g := &Group{}
+	err := x.children(func(start xml.StartElement) error {
+		var err error
+		switch start.Name.Local {
+		case "UUID":
+			g.UUID, err = x.text(start)
+		case "Name":
+			g.Name, err = x.text(start)
+		case "Group":
+			var sub *Group
+			if sub, err = x.group(); err == nil {
+				g.Groups = append(g.Groups, sub)
+			}
+		case "Entry":
+			var e *Entry
+			if e, err = x.entry(); err == nil {
+				g.Entries = append(g.Entries, e)
+			}
+		default:
+			err = x.dec.Skip()
+		}
+		return err
+	})
+	return g, err
+}
+
+func (x *xmlReader) entry() (*Entry, error) {
+	e := &Entry{}
+	err := x.children(func(start xml.StartElement) error {
+		var err error
+		switch start.Name.Local {
+		case "UUID":
+			e.UUID, err = x.text(start)
+		case "String":
+			var s String
+			if s, err = x.string(); err == nil {
+				e.Strings = append(e.Strings, s)
+			}
+		case "Binary":
+			err = x.children(func(start xml.StartElement) error {
+				if start.Name.Local != "Key" {
+					return x.dec.Skip()
+				}
+				name, err := x.text(start)
+				e.Attachments = append(e.Attachments, name)
+				return err
+			})
+		case "History":
+			err = x.children(func(start xml.StartElement) error {
+				if start.Name.Local != "Entry" {
+					return x.dec.Skip()
+				}
+				h, err := x.entry()
+				if err == nil {
+					e.History = append(e.History, h)
+				}
+				return err
+			})
+		default:
+			err = x.dec.Skip()
+		}
+		return err
+	})
+	return e, err
+}
+
+func (x *xmlReader) string() (String, error) {
+	var s String
+	err := x.children(func(start xml.StartElement) error {
+		var err error
+		switch start.Name.Local {
+		case "Key":
+			s.Key, err = x.text(start)
+		case "Value":
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "Protected" && strings.EqualFold(attr.Value, "true") {
+					s.Protected = true
+				}
+			}
+			if s.Value, err = x.text(start); err == nil && s.Protected {
+				s.Value, err = x.unprotect(s.Value)
+			}
+		default:
+			err = x.dec.Skip()
+		}
+		return err
+	})
+	return s, err
+}
+
+// unprotect decrypts a protected value with the inner stream.
+func (x *xmlReader) unprotect(value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid protected value")
+	}
+	if x.stream == nil {
+		return "", errors.New("a protected value without the inner random stream")
+	}
+	x.stream.XORKeyStream(data, data)
+	return string(data), nil
+}
@@ -0,0 +1,202 @@
+// Package otp generates RFC 6238 time-based one-time passwords.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Supported hash algorithms.
+const (
+	SHA1   = "SHA1"
+	SHA256 = "SHA256"
+	SHA512 = "SHA512"
+)
+
+const (
+	defaultAlgorithm = SHA1
+	defaultDigits    = 6
+	defaultPeriod    = 30
+	scheme           = "otpauth"
+	totpType         = "totp"
+)
+
+// TOTP is a time-based one-time password generator.
+type TOTP struct {
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	// Period is the code lifetime in seconds.
+	Period int
+	// Issuer and Account are kept from the otpauth:// URI.
+	Issuer  string
+	Account string
+}
+
+// Parse parses an otpauth://totp/ URI or a bare base32 secret with the default parameters:
+// SHA1, 6 digits and a 30 seconds period.
+func Parse(s string) (*TOTP, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "://") {
+		secret, err := decodeSecret(s)
+		if err != nil {
+			return nil, err
+		}
+		return &TOTP{Secret: secret, Algorithm: defaultAlgorithm, Digits: defaultDigits, Period: defaultPeriod}, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid otpauth URI")
+	}
+	if u.Scheme != scheme {
+		return nil, fmt.Errorf("unsupported URI scheme %q, expected %s://", u.Scheme, scheme)
+	}
+	if u.Host != totpType {
+		return nil, fmt.Errorf("unsupported OTP type %q, only %s is supported", u.Host, totpType)
+	}
+
+	q := u.Query()
+	secret, err := decodeSecret(q.Get("secret"))
+	if err != nil {
+		return nil, err
+	}
+	t := &TOTP{
+		Secret:    secret,
+		Algorithm: defaultAlgorithm,
+		Digits:    defaultDigits,
+		Period:    defaultPeriod,
+		Issuer:    q.Get("issuer"),
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		if t.Issuer == "" {
+			t.Issuer = issuer
+		}
+		label = account
+	}
+	t.Account = strings.TrimSpace(label)
+
+	if alg := q.Get("algorithm"); alg != "" {
+		t.Algorithm = strings.ToUpper(alg)
+	}
+	if digits := q.Get("digits"); digits != "" {
+		if t.Digits, err = strconv.Atoi(digits); err != nil {
+			return nil, fmt.Errorf("invalid digits %q", digits)
+		}
+	}
+	if period := q.Get("period"); period != "" {
+		if t.Period, err = strconv.Atoi(period); err != nil {
+			return nil, fmt.Errorf("invalid period %q", period)
+		}
+	}
+	return t, t.validate()
+}
+
+func (t *TOTP) validate() error {
+	if _, err := t.hash(); err != nil {
+		return err
+	}
+	if t.Digits != 6 && t.Digits != 8 {
+		return fmt.Errorf("unsupported number of digits %d, expected 6 or 8", t.Digits)
+	}
+	if t.Period <= 0 {
+		return fmt.Errorf("invalid period %d", t.Period)
+	}
+	return nil
+}
+
+// decodeSecret decodes a base32 secret, spaces, padding and the letter case are ignored.
+func decodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	s = strings.TrimRight(s, "=")
+	if s == "" {
+		return nil, errors.New("empty OTP secret")
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid base32 OTP secret")
+	}
+	return secret, nil
+}
+
+func (t *TOTP) hash() (func() hash.Hash, error) {
+	switch t.Algorithm {
+	case SHA1:
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported OTP algorithm %q", t.Algorithm)
+	}
+}
+
+// Code returns the code valid at the given time.
+func (t *TOTP) Code(at time.Time) (string, error) {
+	h, err := t.hash()
+	if err != nil {
+		return "", err
+	}
+	counter := uint64(at.Unix()) / uint64(t.Period)
+	return hotp(h, t.Secret, counter, t.Digits), nil
+}
+
+// Remaining returns the time the code generated at the given time is valid for.
+func (t *TOTP) Remaining(at time.Time) time.Duration {
+	period := int64(t.Period)
+	return time.Duration(period-at.Unix()%period) * time.Second
+}
+
+// URI returns the otpauth:// URI of the generator.
+func (t *TOTP) URI() string {
+	label := t.Account
+	if t.Issuer != "" {
+		label = t.Issuer + ":" + t.Account
+	}
+
+	q := url.Values{}
+	q.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(t.Secret))
+	if t.Issuer != "" {
+		q.Set("issuer", t.Issuer)
+	}
+	q.Set("algorithm", t.Algorithm)
+	q.Set("digits", strconv.Itoa(t.Digits))
+	q.Set("period", strconv.Itoa(t.Period))
+
+	u := url.URL{Scheme: scheme, Host: totpType, Path: "/" + label, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// hotp is the RFC 4226 HMAC-based one-time password.
+func hotp(h func() hash.Hash, secret []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(h, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
@@ -0,0 +1,137 @@
+package otp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCodeRFC6238 checks the test vectors of RFC 6238 appendix B: 8 digits and a 30 seconds period.
+func TestCodeRFC6238(t *testing.T) {
+	secrets := map[string][]byte{
+		SHA1:   []byte("12345678901234567890"),
+		SHA256: []byte("12345678901234567890123456789012"),
+		SHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	tests := []struct {
+		unix  int64
+		codes map[string]string
+	}{
+		{59, map[string]string{SHA1: "94287082", SHA256: "46119246", SHA512: "90693936"}},
+		{1111111109, map[string]string{SHA1: "07081804", SHA256: "68084774", SHA512: "25091201"}},
+		{1111111111, map[string]string{SHA1: "14050471", SHA256: "67062674", SHA512: "99943326"}},
+		{1234567890, map[string]string{SHA1: "89005924", SHA256: "91819424", SHA512: "93441116"}},
+		{2000000000, map[string]string{SHA1: "69279037", SHA256: "90698825", SHA512: "38618901"}},
+		{20000000000, map[string]string{SHA1: "65353130", SHA256: "77737706", SHA512: "47863826"}},
+	}
+	for _, tt := range tests {
+		for alg, want := range tt.codes {
+			totp := &TOTP{Secret: secrets[alg], Algorithm: alg, Digits: 8, Period: 30}
+			got, err := totp.Code(time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%s at %d: got %s, want %s", alg, tt.unix, got, want)
+			}
+		}
+	}
+}
+
+// TestCodeDigitsPeriod checks 6 digit codes with the RFC 4226 HOTP vectors and custom periods.
+func TestCodeDigitsPeriod(t *testing.T) {
+	hotpCodes := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	secret := []byte("12345678901234567890")
+	for _, period := range []int{30, 60, 15} {
+		totp := &TOTP{Secret: secret, Algorithm: SHA1, Digits: 6, Period: period}
+		for counter, want := range hotpCodes {
+			// the last second of the period still gives the code of the counter
+			at := time.Unix(int64((counter+1)*period-1), 0)
+			got, err := totp.Code(at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("period %d, counter %d: got %s, want %s", period, counter, got, want)
+			}
+			if remaining := totp.Remaining(at); remaining != time.Second {
+				t.Errorf("period %d, counter %d: %v remaining, want 1s", period, counter, remaining)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	secret := []byte("Hello!\xde\xad\xbe\xef")
+	tests := []struct {
+		name string
+		s    string
+		want TOTP
+	}{
+		{
+			name: "bare secret",
+			s:    " jbsw y3dp ehpk 3pxp ",
+			want: TOTP{Secret: secret, Algorithm: SHA1, Digits: 6, Period: 30},
+		},
+		{
+			name: "issuer and account label",
+			s:    "otpauth://totp/ACME%20Co:john@example.com?secret=JBSWY3DPEHPK3PXP",
+			want: TOTP{Secret: secret, Algorithm: SHA1, Digits: 6, Period: 30, Issuer: "ACME Co", Account: "john@example.com"},
+		},
+		{
+			name: "issuer parameter wins",
+			s:    "otpauth://totp/Old:john?secret=JBSWY3DPEHPK3PXP&issuer=New",
+			want: TOTP{Secret: secret, Algorithm: SHA1, Digits: 6, Period: 30, Issuer: "New", Account: "john"},
+		},
+		{
+			name: "lowercase algorithm",
+			s:    "otpauth://totp/john?secret=JBSWY3DPEHPK3PXP&algorithm=sha512&digits=8&period=60",
+			want: TOTP{Secret: secret, Algorithm: SHA512, Digits: 8, Period: 60, Account: "john"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+			// the URI keeps every parameter
+			again, err := Parse(got.URI())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(again, got) {
+				t.Errorf("%s is parsed as %+v", got.URI(), *again)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		s   string
+		err string
+	}{
+		{"", "empty OTP secret"},
+		{"not base32!", "invalid base32"},
+		{"otpauth://hotp/john?secret=JBSWY3DPEHPK3PXP&counter=1", "unsupported OTP type"},
+		{"https://totp/john?secret=JBSWY3DPEHPK3PXP", "unsupported URI scheme"},
+		{"otpauth://totp/john", "empty OTP secret"},
+		{"otpauth://totp/john?secret=JBSWY3DPEHPK3PXP&algorithm=MD5", "unsupported OTP algorithm"},
+		{"otpauth://totp/john?secret=JBSWY3DPEHPK3PXP&digits=7", "unsupported number of digits"},
+		{"otpauth://totp/john?secret=JBSWY3DPEHPK3PXP&digits=six", "invalid digits"},
+		{"otpauth://totp/john?secret=JBSWY3DPEHPK3PXP&period=0", "invalid period"},
+		{"otpauth://totp/john?secret=JBSWY3DPEHPK3PXP&period=-30", "invalid period"},
+		{"otpauth://totp/john?secret=JBSWY3DPEHPK3PXP&period=1m", "invalid period"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.s)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: got %v, want %q", tt.s, err, tt.err)
+		}
+	}
+}
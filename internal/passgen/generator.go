@@ -3,6 +3,8 @@ package passgen
 import (
 	"crypto/rand"
 	"encoding/binary"
+	"io"
+	"math"
 
 	"github.com/pkg/errors"
 )
@@ -19,45 +21,130 @@ var (
 	wierdSignsPack3    = []rune("²³¹ºª¼½¾×±")
 )
 
+// weightedAlphabet is an alphabet picked with the chance in percent.
+type weightedAlphabet struct {
+	percent  int
+	alphabet []rune
+}
+
+var (
+	safeLetters = []weightedAlphabet{
+		{50, specialSymbols},
+		{16, verySpecialSymbols},
+		{22, numbers},
+		{12, separators},
+	}
+	insaneLetters = []weightedAlphabet{
+		{11, vowels},
+		{11, consonants},
+		{11, separators},
+		{11, numbers},
+		{11, specialSymbols},
+		{11, verySpecialSymbols},
+		{11, wierdSignsPack1},
+		{11, wierdSignsPart2},
+		{12, wierdSignsPack3},
+	}
+)
+
+// Lengths of generated passwords and their pronounceable words, max lengths are exclusive.
+const (
+	readableMinLength = 12
+	safeMinLength     = 18
+	safeMaxLength     = 25
+	insaneMinLength   = 27
+	insaneMaxLength   = 40
+	wordMinLength     = 4
+	wordMaxLength     = 8
+)
+
 type Generator struct {
-	randomInts []int
+	// source is the random source, crypto/rand for the generators returned by New.
+	source     io.Reader
+	randomInts []uint32
+	// err is the first error of reading random values, generated passwords are discarded after it.
+	err error
 }
 
+const randomBatchLen = 128
+
 // New returns Generator with 128 random generated values.
 func New() (*Generator, error) {
-	const randomBatchLen = 128
+	g := &Generator{source: rand.Reader}
+	if err := g.fill(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
 
-	rnd := make([]byte, randomBatchLen*4) // 4 byte for 1 int32
-	_, err := rand.Read(rnd)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to read random sequence")
+// fill reads a new batch of random values.
+func (g *Generator) fill() error {
+	rnd := make([]byte, randomBatchLen*4) // 4 byte for 1 uint32
+	if _, err := io.ReadFull(g.source, rnd); err != nil {
+		return errors.Wrap(err, "unable to read random sequence")
 	}
 
-	g := Generator{randomInts: make([]int, 0, randomBatchLen)}
+	g.randomInts = make([]uint32, 0, randomBatchLen)
 	for i := 0; i < len(rnd); i += 4 {
-		// Read 4 bytes into num
-		num := binary.BigEndian.Uint32(rnd[i : i+4])
-		g.randomInts = append(g.randomInts, int(num))
+		g.randomInts = append(g.randomInts, binary.BigEndian.Uint32(rnd[i:i+4]))
 	}
-	return &g, nil
+	return nil
 }
 
-func (g *Generator) RandIntn(n int) int {
+func (g *Generator) next() (uint32, error) {
+	if len(g.randomInts) == 0 {
+		if err := g.fill(); err != nil {
+			return 0, err
+		}
+	}
 	r := g.randomInts[0]
-	if len(g.randomInts) == 1 {
-		// skipping error as this case is working only when we trying to get
-		// >64 random values from the Generator, which is should not ever happen.
-		newGen, _ := New()
-		g.randomInts = newGen.randomInts
+	g.randomInts = g.randomInts[1:]
+	return r, nil
+}
+
+// RandIntn returns a uniformly distributed random number in [0, n).
+// Values from the top of the uint32 range which would make r % n biased are rejected and drawn again.
+func (g *Generator) RandIntn(n int) (int, error) {
+	if n <= 0 || uint64(n) > math.MaxUint32 {
+		return 0, errors.Errorf("invalid random range %d", n)
 	}
 
-	g.randomInts = g.randomInts[1:]
-	return r % n
+	const rangeSize = uint64(math.MaxUint32) + 1
+	limit := rangeSize - rangeSize%uint64(n)
+	for {
+		r, err := g.next()
+		if err != nil {
+			return 0, err
+		}
+		if uint64(r) < limit {
+			return int(uint64(r) % uint64(n)), nil
+		}
+	}
+}
+
+// intn is RandIntn keeping the first error in g.err, so the generators don't check every call.
+func (g *Generator) intn(n int) int {
+	if g.err != nil {
+		return 0
+	}
+	r, err := g.RandIntn(n)
+	if err != nil {
+		g.err = err
+		return 0
+	}
+	return r
+}
+
+// result returns the generated password or the error which happened while it was generated.
+func (g *Generator) result(word []rune) (string, error) {
+	if g.err != nil {
+		return "", g.err
+	}
+	return string(word), nil
 }
 
 // GenReadablePass looks like *word**number**separator**word**number**separator**specialSymbol*
-func (g *Generator) GenReadablePass() string {
-	const minWordLen = 12
+func (g *Generator) GenReadablePass() (string, error) {
 	word := make([]rune, 0)
 
 	// word
@@ -69,33 +156,28 @@ func (g *Generator) GenReadablePass() string {
 
 	num := func() []rune {
 		num := []rune{g.randomNumber()}
-		if g.RandIntn(2) == 1 { // 50%
+		if g.intn(2) == 1 { // 50%
 			num = append(num, g.randomNumber())
-			if g.RandIntn(2) == 1 { // 25%
+			if g.intn(2) == 1 { // 25%
 				num = append(num, g.randomNumber())
 			}
 		}
 		return num
 	}()
-	randomPlace := g.RandIntn(len(word))
+	randomPlace := g.intn(len(word))
 	word = append(word[:randomPlace], append(num, word[randomPlace:]...)...)
 
-	randomPlace = g.RandIntn(len(word))
+	randomPlace = g.intn(len(word))
 	word = append(word[:randomPlace], append([]rune{g.randomSpecialSymbol()}, word[randomPlace:]...)...)
-	for len(word) < minWordLen {
-		randomPlace = g.RandIntn(len(word))
+	for len(word) < readableMinLength {
+		randomPlace = g.intn(len(word))
 		word = append(word[:randomPlace], append([]rune{g.randomSpecialSymbol()}, word[randomPlace:]...)...)
 	}
 
-	return string(word)
+	return g.result(word)
 }
 
-func (g *Generator) GenSafePass() string {
-	const (
-		minLength = 18
-		maxLength = 25
-	)
-
+func (g *Generator) GenSafePass() (string, error) {
 	word := make([]rune, 0)
 	// word
 	word = append(word, g.generatePronounceableWord()...)
@@ -110,77 +192,55 @@ func (g *Generator) GenSafePass() string {
 
 	num := func() []rune {
 		num := []rune{g.randomNumber()}
-		if g.RandIntn(2) == 1 { // 50%
+		if g.intn(2) == 1 { // 50%
 			num = append(num, g.randomNumber())
-			if g.RandIntn(2) == 1 { // 25%
+			if g.intn(2) == 1 { // 25%
 				num = append(num, g.randomNumber())
 			}
 		}
 		return num
 	}()
-	randomPlace := g.RandIntn(len(word))
+	randomPlace := g.intn(len(word))
 	word = append(word[:randomPlace], append(num, word[randomPlace:]...)...)
-	randomPlace = g.RandIntn(len(word))
+	randomPlace = g.intn(len(word))
 	word = append(word[:randomPlace], append([]rune{g.randomVerySpecialSymbol()}, word[randomPlace:]...)...)
 
-	length := g.RandIntn(maxLength-minLength) + minLength
+	length := g.intn(safeMaxLength-safeMinLength) + safeMinLength
 	for i := len(word); i < length; i++ {
-		randomPlace = g.RandIntn(len(word))
+		randomPlace = g.intn(len(word))
 		word = append(word[:randomPlace], append([]rune{g.randomSafeLetter()}, word[randomPlace:]...)...)
 	}
-	return string(word)
+	return g.result(word)
 }
 
-func (g *Generator) GenInsanePass() string {
-	const (
-		minLength = 27
-		maxLength = 40
-	)
-
-	length := g.RandIntn(maxLength-minLength) + minLength
+func (g *Generator) GenInsanePass() (string, error) {
+	length := g.intn(insaneMaxLength-insaneMinLength) + insaneMinLength
 	word := make([]rune, length)
 	for i := range word {
 		word[i] = g.randomInsaneLetter()
 	}
-	return string(word)
+	return g.result(word)
 }
 
 func (g *Generator) randomSafeLetter() rune {
-	letterType := g.RandIntn(100)
-	switch {
-	case letterType < 50:
-		return g.randomSpecialSymbol()
-	case letterType < 66:
-		return g.randomVerySpecialSymbol()
-	case letterType < 88:
-		return g.randomNumber()
-	default:
-		return g.randomSeparator()
-	}
+	return g.randomWeighted(safeLetters)
 }
 
 func (g *Generator) randomInsaneLetter() rune {
-	letterType := g.RandIntn(100)
-	switch {
-	case letterType < 11:
-		return g.randomVowel()
-	case letterType < 22:
-		return g.randomConsonant()
-	case letterType < 33:
-		return g.randomSeparator()
-	case letterType < 44:
-		return g.randomNumber()
-	case letterType < 55:
-		return g.randomSpecialSymbol()
-	case letterType < 66:
-		return g.randomVerySpecialSymbol()
-	case letterType < 77:
-		return g.randomWierdPack1()
-	case letterType < 88:
-		return g.randomWierdPack2()
-	default:
-		return g.randomWierdPack3()
+	return g.randomWeighted(insaneLetters)
+}
+
+// randomWeighted picks an alphabet by its chance and a letter of it, the chances add up to 100.
+func (g *Generator) randomWeighted(letters []weightedAlphabet) rune {
+	letterType := g.intn(100)
+	for _, l := range letters[:len(letters)-1] {
+		if letterType < l.percent {
+			return l.alphabet[g.intn(len(l.alphabet))]
+		}
+		letterType -= l.percent
 	}
+	last := letters[len(letters)-1].alphabet
+	return last[g.intn(len(last))]
 }
 
 func (g *Generator) randomSyllable() []rune {
@@ -190,17 +250,12 @@ func (g *Generator) randomSyllable() []rune {
 		{g.randomVowel(), g.randomConsonant(), g.randomVowel()},
 		{g.randomConsonant(), g.randomVowel(), g.randomConsonant()},
 	}
-	return syllables[g.RandIntn(len(syllables))]
+	return syllables[g.intn(len(syllables))]
 }
 
 func (g *Generator) generatePronounceableWord() []rune {
-	const (
-		minWordLen = 4
-		maxWordLen = 8
-	)
-
 	word := make([]rune, 0, 20)
-	length := g.RandIntn(maxWordLen-minWordLen) + minWordLen
+	length := g.intn(wordMaxLength-wordMinLength) + wordMinLength
 	for len(word) < length {
 		word = append(word, g.randomSyllable()...)
 	}
@@ -208,37 +263,25 @@ func (g *Generator) generatePronounceableWord() []rune {
 }
 
 func (g *Generator) randomVowel() rune {
-	return rune(vowels[g.RandIntn(len(vowels))])
+	return rune(vowels[g.intn(len(vowels))])
 }
 
 func (g *Generator) randomConsonant() rune {
-	return rune(consonants[g.RandIntn(len(consonants))])
+	return rune(consonants[g.intn(len(consonants))])
 }
 
 func (g *Generator) randomSeparator() rune {
-	return rune(separators[g.RandIntn(len(separators))])
+	return rune(separators[g.intn(len(separators))])
 }
 
 func (g *Generator) randomNumber() rune {
-	return rune(numbers[g.RandIntn(len(numbers))])
+	return rune(numbers[g.intn(len(numbers))])
 }
 
 func (g *Generator) randomSpecialSymbol() rune {
-	return rune(specialSymbols[g.RandIntn(len(specialSymbols))])
+	return rune(specialSymbols[g.intn(len(specialSymbols))])
 }
 
 func (g *Generator) randomVerySpecialSymbol() rune {
-	return rune(verySpecialSymbols[g.RandIntn(len(verySpecialSymbols))])
-}
-
-func (g *Generator) randomWierdPack1() rune {
-	return wierdSignsPack1[g.RandIntn(len(wierdSignsPack1))]
-}
-
-func (g *Generator) randomWierdPack2() rune {
-	return wierdSignsPart2[g.RandIntn(len(wierdSignsPart2))]
-}
-
-func (g *Generator) randomWierdPack3() rune {
-	return wierdSignsPack3[g.RandIntn(len(wierdSignsPack3))]
+	return rune(verySpecialSymbols[g.intn(len(verySpecialSymbols))])
 }
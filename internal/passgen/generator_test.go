@@ -0,0 +1,229 @@
+package passgen
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+const (
+	// samplesPerValue is the expected number of samples in every bucket of a chi-squared test.
+	samplesPerValue = 2000
+	// zCritical is the standard normal quantile of the significance level 0.001.
+	zCritical = 3.0902
+)
+
+// seededGenerator returns a Generator reading a fixed ChaCha8 stream, so the tests are reproducible.
+func seededGenerator(seed byte) *Generator {
+	var s [32]byte
+	s[0] = seed
+	return &Generator{source: rand.NewChaCha8(s)}
+}
+
+// chiSquaredCritical approximates the chi-squared quantile with the Wilson-Hilferty transformation.
+func chiSquaredCritical(df int) float64 {
+	k := float64(df)
+	v := 1 - 2/(9*k) + zCritical*math.Sqrt(2/(9*k))
+	return k * v * v * v
+}
+
+// chiSquared returns the chi-squared statistic of the observed counts against the expected probabilities.
+func chiSquared(observed []int, probs []float64, total int) float64 {
+	var stat float64
+	for i, p := range probs {
+		expected := p * float64(total)
+		d := float64(observed[i]) - expected
+		stat += d * d / expected
+	}
+	return stat
+}
+
+func ceilDiv(a, b uint64) uint64 {
+	return (a + b - 1) / b
+}
+
+func assertDistribution(t *testing.T, observed []int, probs []float64, total int) {
+	t.Helper()
+	stat, critical := chiSquared(observed, probs, total), chiSquaredCritical(len(observed)-1)
+	if stat > critical {
+		t.Errorf("chi-squared %.2f exceeds the critical value %.2f with %d degrees of freedom", stat, critical, len(observed)-1)
+	}
+}
+
+func TestRandIntnUniform(t *testing.T) {
+	// buckets groups large ranges, values are mapped to v*buckets/n
+	const buckets = 64
+	bigN := int(uint(1)<<31) + 1 // just above 2^31, half of the uint32 range is rejected
+	for _, n := range []int{3, 7, 10, 31, 42, 100, 1000, 1<<20 + 7, 3_000_000_019 / 2, bigN, math.MaxUint32 - 5} {
+		if n <= 0 {
+			continue // int is 32 bits
+		}
+		k := min(n, buckets)
+		g := seededGenerator(byte(n))
+		observed := make([]int, k)
+		total := 2 * k * samplesPerValue
+		for range total {
+			v, err := g.RandIntn(n)
+			if err != nil {
+				t.Fatalf("RandIntn(%d): %v", n, err)
+			}
+			if v < 0 || v >= n {
+				t.Fatalf("RandIntn(%d) returned %d", n, v)
+			}
+			observed[uint64(v)*uint64(k)/uint64(n)]++
+		}
+		// bucket b holds the values in [ceil(b*n/k), ceil((b+1)*n/k))
+		probs := make([]float64, k)
+		for b := range probs {
+			lo, hi := ceilDiv(uint64(b)*uint64(n), uint64(k)), ceilDiv(uint64(b+1)*uint64(n), uint64(k))
+			probs[b] = float64(hi-lo) / float64(n)
+		}
+		assertDistribution(t, observed, probs, total)
+	}
+}
+
+func TestRandIntnRejectsBiasedValues(t *testing.T) {
+	// 2^32 % 3 == 1, so MaxUint32 is the only value rejected for n = 3
+	g := &Generator{randomInts: []uint32{math.MaxUint32, math.MaxUint32 - 1}}
+	v, err := g.RandIntn(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int((math.MaxUint32 - 1) % 3); v != want {
+		t.Errorf("RandIntn(3) = %d, want %d", v, want)
+	}
+	if len(g.randomInts) != 0 {
+		t.Errorf("%d random values left, want 0", len(g.randomInts))
+	}
+}
+
+func TestRandIntnInvalidRange(t *testing.T) {
+	g := seededGenerator(1)
+	for _, n := range []int{0, -1} {
+		if _, err := g.RandIntn(n); err == nil {
+			t.Errorf("RandIntn(%d) didn't fail", n)
+		}
+	}
+}
+
+func TestAlphabetsUniform(t *testing.T) {
+	tests := []struct {
+		name     string
+		alphabet []rune
+		random   func(*Generator) rune
+	}{
+		{"vowels", vowels, (*Generator).randomVowel},
+		{"consonants", consonants, (*Generator).randomConsonant},
+		{"separators", separators, (*Generator).randomSeparator},
+		{"numbers", numbers, (*Generator).randomNumber},
+		{"specialSymbols", specialSymbols, (*Generator).randomSpecialSymbol},
+		{"verySpecialSymbols", verySpecialSymbols, (*Generator).randomVerySpecialSymbol},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probs := make([]float64, len(tt.alphabet))
+			for j := range probs {
+				probs[j] = 1 / float64(len(tt.alphabet))
+			}
+			sampleLetters(t, seededGenerator(byte(i)), tt.alphabet, probs, tt.random)
+		})
+	}
+}
+
+// TestMixedLettersDistribution checks every letter of the weighted alphabets, the wierdSigns ones
+// are only picked through insaneLetters.
+func TestMixedLettersDistribution(t *testing.T) {
+	tests := []struct {
+		name    string
+		letters []weightedAlphabet
+		random  func(*Generator) rune
+	}{
+		{"randomSafeLetter", safeLetters, (*Generator).randomSafeLetter},
+		{"randomInsaneLetter", insaneLetters, (*Generator).randomInsaneLetter},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var alphabet []rune
+			var probs []float64
+			sum := 0
+			for _, l := range tt.letters {
+				sum += l.percent
+				for _, r := range l.alphabet {
+					alphabet = append(alphabet, r)
+					probs = append(probs, float64(l.percent)/100/float64(len(l.alphabet)))
+				}
+			}
+			if sum != 100 {
+				t.Fatalf("the chances add up to %d", sum)
+			}
+			sampleLetters(t, seededGenerator(byte(100+i)), alphabet, probs, tt.random)
+		})
+	}
+}
+
+// sampleLetters draws letters until the least likely one is expected samplesPerValue times and checks
+// the counts against probs.
+func sampleLetters(t *testing.T, g *Generator, alphabet []rune, probs []float64, random func(*Generator) rune) {
+	t.Helper()
+	index := make(map[rune]int, len(alphabet))
+	for i, r := range alphabet {
+		if _, ok := index[r]; ok {
+			t.Fatalf("letter %q repeats in the alphabet", r)
+		}
+		index[r] = i
+	}
+
+	least := probs[0]
+	for _, p := range probs {
+		least = min(least, p)
+	}
+	total := int(samplesPerValue / least)
+	observed := make([]int, len(alphabet))
+	for range total {
+		r := random(g)
+		i, ok := index[r]
+		if !ok {
+			t.Fatalf("unexpected letter %q", r)
+		}
+		observed[i]++
+	}
+	if g.err != nil {
+		t.Fatal(g.err)
+	}
+	assertDistribution(t, observed, probs, total)
+}
+
+var errSource = errors.New("source failed")
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errSource }
+
+func TestRandomSourceErrors(t *testing.T) {
+	g := &Generator{source: failingReader{}}
+	if _, err := g.RandIntn(10); !errors.Is(err, errSource) {
+		t.Errorf("RandIntn: got %v, want %v", err, errSource)
+	}
+
+	for name, gen := range map[string]func(*Generator) (string, error){
+		"GenReadablePass": (*Generator).GenReadablePass,
+		"GenSafePass":     (*Generator).GenSafePass,
+		"GenInsanePass":   (*Generator).GenInsanePass,
+	} {
+		// the first batch is read, the error comes from one of the next ones
+		g := seededGenerator(7)
+		if err := g.fill(); err != nil {
+			t.Fatal(err)
+		}
+		g.randomInts = g.randomInts[:3]
+		g.source = failingReader{}
+		pass, err := gen(g)
+		if !errors.Is(err, errSource) {
+			t.Errorf("%s: got %v, want %v", name, err, errSource)
+		}
+		if pass != "" {
+			t.Errorf("%s returned %q with an error", name, pass)
+		}
+	}
+}
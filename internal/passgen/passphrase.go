@@ -0,0 +1,149 @@
+package passgen
+
+import (
+	_ "embed"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// effLargeWordList is the EFF large wordlist for five dice, 7776 words:
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases
+//
+//go:embed eff_large_wordlist.txt
+var effLargeWordList string
+
+// Capitalization modes of a passphrase.
+const (
+	CapitalizeNone   = ""
+	CapitalizeFirst  = "first"
+	CapitalizeAll    = "all"
+	CapitalizeRandom = "random"
+)
+
+const defaultPassphraseWords = 6
+
+// PassphraseOptions describe a diceware passphrase.
+type PassphraseOptions struct {
+	Words     int
+	Separator string
+	// Capitalize is one of the Capitalize* modes, random capitalizes each word with a 50% chance.
+	Capitalize string
+	// Digit appends a random digit to a random word.
+	Digit bool
+	// WordList is the list to pick words from, the EFF large wordlist if it's empty.
+	WordList []string
+}
+
+// DefaultPassphraseOptions returns options of 6 words from the EFF large wordlist separated by '-'.
+func DefaultPassphraseOptions() PassphraseOptions {
+	return PassphraseOptions{Words: defaultPassphraseWords, Separator: "-"}
+}
+
+// EFFWordList returns the embedded EFF large wordlist.
+func EFFWordList() []string {
+	return effWords()
+}
+
+var effWords = sync.OnceValue(func() []string {
+	words, _ := parseWordList(effLargeWordList)
+	return words
+})
+
+// LoadWordList reads a wordlist file: one word per line, optionally prefixed with dice rolls
+// like in the EFF lists. Duplicates are skipped, so they don't inflate the entropy.
+func LoadWordList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read wordlist")
+	}
+	return parseWordList(string(data))
+}
+
+func parseWordList(data string) ([]string, error) {
+	seen := make(map[string]bool)
+	var words []string
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		word := fields[len(fields)-1]
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+
+	if len(words) < 2 {
+		return nil, errors.New("the wordlist must have at least 2 different words")
+	}
+	return words, nil
+}
+
+// Entropy returns the number of bits of entropy of passphrases generated with the options,
+// provided that words in the list and the separator are distinct.
+func (o PassphraseOptions) Entropy() float64 {
+	listLen := len(o.WordList)
+	if listLen == 0 {
+		listLen = len(EFFWordList())
+	}
+
+	bits := float64(o.Words) * math.Log2(float64(listLen))
+	if o.Capitalize == CapitalizeRandom {
+		bits += float64(o.Words)
+	}
+	if o.Digit {
+		// a digit and the word it's appended to
+		bits += math.Log2(float64(len(numbers))) + math.Log2(float64(o.Words))
+	}
+	return bits
+}
+
+// GenPassphrase generates a passphrase of random words and returns it with its entropy in bits.
+func (g *Generator) GenPassphrase(o PassphraseOptions) (string, float64, error) {
+	if o.Words <= 0 {
+		return "", 0, errors.Errorf("invalid number of words %d", o.Words)
+	}
+	switch o.Capitalize {
+	case CapitalizeNone, CapitalizeFirst, CapitalizeAll, CapitalizeRandom:
+	default:
+		return "", 0, errors.Errorf("unknown capitalization %q, expected %s, %s or %s", o.Capitalize, CapitalizeFirst, CapitalizeAll, CapitalizeRandom)
+	}
+	if len(o.WordList) == 0 {
+		o.WordList = EFFWordList()
+	}
+
+	words := make([]string, o.Words)
+	for i := range words {
+		word := o.WordList[g.intn(len(o.WordList))]
+		switch {
+		case o.Capitalize == CapitalizeFirst, o.Capitalize == CapitalizeRandom && g.intn(2) == 1:
+			word = capitalize(word)
+		case o.Capitalize == CapitalizeAll:
+			word = strings.ToUpper(word)
+		}
+		words[i] = word
+	}
+	if o.Digit {
+		i := g.intn(len(words))
+		words[i] += string(g.randomNumber())
+	}
+
+	pass, err := g.result([]rune(strings.Join(words, o.Separator)))
+	if err != nil {
+		return "", 0, err
+	}
+	return pass, o.Entropy(), nil
+}
+
+func capitalize(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + word[size:]
+}
@@ -0,0 +1,174 @@
+package passgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Character classes of a Policy.
+const (
+	ClassLower  = "lower"
+	ClassUpper  = "upper"
+	ClassDigit  = "digit"
+	ClassSymbol = "symbol"
+)
+
+var classAlphabets = map[string][]rune{
+	ClassLower:  []rune("abcdefghijklmnopqrstuvwxyz"),
+	ClassUpper:  []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ"),
+	ClassDigit:  numbers,
+	ClassSymbol: append(append(append([]rune{}, specialSymbols...), separators...), verySpecialSymbols...),
+}
+
+// Classes are the character class names in the default charset order.
+var Classes = []string{ClassLower, ClassUpper, ClassDigit, ClassSymbol}
+
+// ambiguousChars look alike in many fonts.
+var ambiguousChars = []rune("0Oo1lI|`'\"")
+
+const (
+	defaultPolicyLength = 20
+	maxPolicyLength     = 1024
+)
+
+// Policy describes passwords accepted by a site.
+type Policy struct {
+	// MinLength and MaxLength are the password length range, a random length in it is used.
+	MinLength int
+	MaxLength int
+	// Charset is the allowed characters, all classes if empty.
+	Charset []rune
+	// Require is the minimum number of characters of each class.
+	Require map[string]int
+	// Forbidden characters are removed from the charset.
+	Forbidden []rune
+	// NoAmbiguous removes characters which look alike, e.g. 0 and O, from the charset.
+	NoAmbiguous bool
+}
+
+// DefaultPolicy returns a policy of 20 characters of all classes with at least one of each.
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength: defaultPolicyLength,
+		MaxLength: defaultPolicyLength,
+		Require:   map[string]int{ClassLower: 1, ClassUpper: 1, ClassDigit: 1, ClassSymbol: 1},
+	}
+}
+
+// ClassCharset returns the characters of the class.
+func ClassCharset(class string) ([]rune, error) {
+	alphabet, ok := classAlphabets[class]
+	if !ok {
+		return nil, fmt.Errorf("unknown character class %q, expected one of %s", class, strings.Join(Classes, ", "))
+	}
+	return alphabet, nil
+}
+
+// alphabet returns the allowed characters without duplicates.
+func (p Policy) alphabet() []rune {
+	charset := p.Charset
+	if len(charset) == 0 {
+		for _, class := range Classes {
+			charset = append(charset, classAlphabets[class]...)
+		}
+	}
+
+	excluded := make(map[rune]bool)
+	for _, r := range p.Forbidden {
+		excluded[r] = true
+	}
+	if p.NoAmbiguous {
+		for _, r := range ambiguousChars {
+			excluded[r] = true
+		}
+	}
+
+	res := make([]rune, 0, len(charset))
+	for _, r := range charset {
+		if !excluded[r] {
+			res = append(res, r)
+			excluded[r] = true // skip duplicates
+		}
+	}
+	return res
+}
+
+// classAlphabet returns the allowed characters of the class.
+func classAlphabet(alphabet []rune, class string) []rune {
+	inClass := make(map[rune]bool)
+	for _, r := range classAlphabets[class] {
+		inClass[r] = true
+	}
+
+	var res []rune
+	for _, r := range alphabet {
+		if inClass[r] {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// Validate checks that a password satisfying the policy exists.
+func (p Policy) Validate() error {
+	if p.MinLength <= 0 || p.MaxLength < p.MinLength {
+		return fmt.Errorf("invalid length range %d-%d", p.MinLength, p.MaxLength)
+	}
+	if p.MaxLength > maxPolicyLength {
+		return fmt.Errorf("the password can't be longer than %d characters", maxPolicyLength)
+	}
+
+	alphabet := p.alphabet()
+	if len(alphabet) == 0 {
+		return errors.New("no characters are allowed by the policy")
+	}
+
+	required := 0
+	for class, n := range p.Require {
+		if _, err := ClassCharset(class); err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid number of %s characters %d", class, n)
+		}
+		if n > 0 && len(classAlphabet(alphabet, class)) == 0 {
+			return fmt.Errorf("%s characters are required, but none of them are allowed", class)
+		}
+		required += n
+	}
+	if required > p.MinLength {
+		return fmt.Errorf("%d characters are required, but the password can be %d characters long", required, p.MinLength)
+	}
+	return nil
+}
+
+// GenerateWithPolicy generates a password satisfying the policy.
+func (g *Generator) GenerateWithPolicy(p Policy) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", errors.Wrap(err, "invalid password policy")
+	}
+
+	alphabet := p.alphabet()
+	length := p.MinLength + g.intn(p.MaxLength-p.MinLength+1)
+
+	word := make([]rune, 0, length)
+	// required characters first, in the fixed class order so the result doesn't depend on map iteration
+	for _, class := range Classes {
+		classChars := classAlphabet(alphabet, class)
+		for i := 0; i < p.Require[class]; i++ {
+			word = append(word, classChars[g.intn(len(classChars))])
+		}
+	}
+	for len(word) < length {
+		word = append(word, alphabet[g.intn(len(alphabet))])
+	}
+
+	// Fisher-Yates shuffle, so required characters can be anywhere
+	for i := len(word) - 1; i > 0; i-- {
+		j := g.intn(i + 1)
+		word[i], word[j] = word[j], word[i]
+	}
+	return g.result(word)
+}
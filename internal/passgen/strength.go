@@ -0,0 +1,481 @@
+package passgen
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Strength is an estimate of how hard the password is to guess.
+type Strength struct {
+	// Entropy is the estimated number of bits, the number of guesses is about 2^Entropy.
+	Entropy float64
+	// Warnings name the patterns found in the password, e.g. dictionary words or dates.
+	Warnings []string
+}
+
+// Strength levels by entropy in bits.
+const (
+	WeakEntropy   = 36
+	FairEntropy   = 60
+	StrongEntropy = 80
+)
+
+// Level returns a human readable strength level.
+func (s Strength) Level() string {
+	switch {
+	case s.Entropy < WeakEntropy:
+		return "very weak"
+	case s.Entropy < FairEntropy:
+		return "weak"
+	case s.Entropy < StrongEntropy:
+		return "fair"
+	default:
+		return "strong"
+	}
+}
+
+// Entropy returns the entropy in bits of passwords generated with the policy.
+// It's the lower bound for the shortest length, required classes are not counted.
+func (p Policy) Entropy() float64 {
+	return float64(p.MinLength) * math.Log2(float64(len(p.alphabet())))
+}
+
+// ReadableEntropy returns the entropy in bits of GenReadablePass passwords. Like Policy.Entropy it's
+// the lower bound for the shortest length: two shortest words, a separator, a digit and special symbols
+// up to the minimum length. Random positions and optional digits are not counted.
+func ReadableEntropy() float64 {
+	symbols := max(1, readableMinLength-(2*wordMinLength+2))
+	return 2*wordEntropy() + log2Len(separators) + log2Len(numbers) + float64(symbols)*log2Len(specialSymbols)
+}
+
+// SafeEntropy returns the lower bound of the entropy in bits of GenSafePass passwords: three shortest words,
+// two separators, a digit, a very special symbol and safe letters up to the minimum length.
+func SafeEntropy() float64 {
+	letters := max(0, safeMinLength-(3*wordMinLength+4))
+	return 3*wordEntropy() + 2*log2Len(separators) + log2Len(numbers) + log2Len(verySpecialSymbols) +
+		float64(letters)*weightedEntropy(safeLetters)
+}
+
+// InsaneEntropy returns the lower bound of the entropy in bits of GenInsanePass passwords of the minimum length.
+func InsaneEntropy() float64 {
+	return insaneMinLength * weightedEntropy(insaneLetters)
+}
+
+// wordEntropy returns the entropy of the shortest pronounceable word: it's longer than a syllable
+// and every syllable has a consonant, the other letters are counted as vowels.
+func wordEntropy() float64 {
+	return log2Len(consonants) + (wordMinLength-1)*log2Len(vowels)
+}
+
+// weightedEntropy returns the entropy of a letter picked from the weighted alphabets, they don't share letters.
+func weightedEntropy(letters []weightedAlphabet) float64 {
+	var bits float64
+	for _, l := range letters {
+		p := float64(l.percent) / 100
+		bits += p * (math.Log2(1/p) + log2Len(l.alphabet))
+	}
+	return bits
+}
+
+func log2Len(alphabet []rune) float64 {
+	return math.Log2(float64(len(alphabet)))
+}
+
+// commonPasswords are the most common leaked passwords, the most common first.
+var commonPasswords = []string{
+	"123456", "password", "12345678", "qwerty", "123456789", "12345", "1234", "111111", "1234567", "dragon",
+	"123123", "baseball", "abc123", "football", "monkey", "letmein", "696969", "shadow", "master", "666666",
+	"qwertyuiop", "123321", "mustang", "1234567890", "michael", "654321", "superman", "1qaz2wsx", "7777777", "121212",
+	"000000", "qazwsx", "123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh", "hunter",
+	"buster", "soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou", "2000", "charlie",
+	"robert", "thomas", "hockey", "ranger", "daniel", "starwars", "klaster", "112233", "george", "computer",
+	"michelle", "jessica", "pepper", "1111", "zxcvbn", "555555", "11111111", "131313", "freedom", "777777",
+	"pass", "maggie", "159753", "aaaaaa", "ginger", "princess", "joshua", "cheese", "amanda", "summer",
+	"love", "ashley", "nicole", "chelsea", "biteme", "matthew", "access", "yankees", "987654321", "dallas",
+	"austin", "thunder", "taylor", "matrix", "admin", "welcome", "login", "passw0rd", "qwerty123", "password1",
+	"solo", "princess1", "abc", "flower", "hello", "secret", "lovely", "whatever", "donald", "changeme",
+}
+
+var commonPasswordRanks = func() map[string]int {
+	res := make(map[string]int, len(commonPasswords))
+	for i, w := range commonPasswords {
+		res[w] = i + 1
+	}
+	return res
+}()
+
+var effWordSet = sync.OnceValue(func() map[string]struct{} {
+	words := EFFWordList()
+	res := make(map[string]struct{}, len(words))
+	for _, w := range words {
+		res[w] = struct{}{}
+	}
+	return res
+})
+
+// wordRank returns the rank of a lowercase word in the common passwords or in the EFF wordlist,
+// all the EFF words have the rank of the list size.
+func wordRank(word string) (rank int, common, ok bool) {
+	if rank, ok := commonPasswordRanks[word]; ok {
+		return rank, true, true
+	}
+	if _, ok := effWordSet()[word]; ok {
+		return len(EFFWordList()), false, true
+	}
+	return 0, false, false
+}
+
+var leetSubstitutions = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i',
+	'|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+const (
+	minPatternLen = 3
+	// maxPatternLen bounds the parts matched as patterns, longer passwords are split into several parts,
+	// so the estimate stays linear in the password length, e.g. for keys and notes kept as passwords
+	maxPatternLen = 32
+	// bits per dictionary word substitution and per turn of a keyboard walk
+	leetBits = 1
+	turnBits = 3
+	// keyboardKeys is the number of keys a keyboard walk can start from
+	keyboardKeys = 47
+	// dateYears is the number of years a date is guessed in
+	dateYears = 130
+)
+
+// match is a part of the password guessed as a pattern.
+type match struct {
+	bits    float64
+	warning string
+}
+
+// EstimateStrength estimates the entropy of an arbitrary password: it's split into the cheapest combination
+// of patterns (common passwords and dictionary words with capitalization and l33t substitutions,
+// keyboard walks, repeats, sequences and dates) and characters guessed by brute force.
+func EstimateStrength(pass string) Strength {
+	runes := []rune(pass)
+	if len(runes) == 0 {
+		return Strength{Warnings: []string{"empty password"}}
+	}
+
+	charBits := math.Log2(float64(cardinality(runes)))
+
+	// best[i] is the minimal entropy of runes[:i]
+	best := make([]float64, len(runes)+1)
+	from := make([]int, len(runes)+1)
+	matched := make([]*match, len(runes)+1)
+	for end := 1; end <= len(runes); end++ {
+		best[end] = best[end-1] + charBits
+		from[end], matched[end] = end-1, nil
+
+		for start := max(0, end-maxPatternLen); start <= end-minPatternLen; start++ {
+			m := bestMatch(runes[start:end])
+			if m == nil {
+				continue
+			}
+			if bits := best[start] + m.bits; bits < best[end] {
+				best[end], from[end], matched[end] = bits, start, m
+			}
+		}
+	}
+
+	var (
+		warnings []string
+		seen     = make(map[string]bool)
+		patterns int
+	)
+	for end := len(runes); end > 0; end = from[end] {
+		if m := matched[end]; m != nil {
+			patterns++
+			if !seen[m.warning] {
+				seen[m.warning] = true
+				warnings = append([]string{m.warning}, warnings...)
+			}
+		}
+	}
+
+	entropy := best[len(runes)]
+	// the attacker also has to guess how the patterns are combined
+	if patterns > 1 {
+		entropy += math.Log2(float64(patterns))
+	}
+	return Strength{Entropy: entropy, Warnings: warnings}
+}
+
+// bestMatch returns the cheapest pattern matching the whole part, nil if there is none.
+func bestMatch(part []rune) *match {
+	var best *match
+	for _, m := range []*match{dictionaryMatch(part), keyboardMatch(part), repeatMatch(part), sequenceMatch(part), dateMatch(part)} {
+		if m != nil && (best == nil || m.bits < best.bits) {
+			best = m
+		}
+	}
+	return best
+}
+
+func dictionaryMatch(part []rune) *match {
+	lower := []rune(strings.ToLower(string(part)))
+
+	word, substitutions := make([]rune, len(lower)), 0
+	for i, r := range lower {
+		if sub, ok := leetSubstitutions[r]; ok {
+			word[i] = sub
+			substitutions++
+		} else {
+			word[i] = r
+		}
+	}
+
+	rank, common, ok := wordRank(string(lower))
+	if ok {
+		substitutions = 0
+	} else if substitutions > 0 {
+		rank, common, ok = wordRank(string(word))
+	}
+	if !ok {
+		return nil
+	}
+
+	bits := math.Log2(float64(rank)) + capitalizationBits(part) + float64(substitutions*leetBits)
+	warning := "dictionary word"
+	if common {
+		warning = "common password"
+	}
+	return &match{bits: bits, warning: warning}
+}
+
+// capitalizationBits estimates the bits of the letter case of a dictionary word.
+func capitalizationBits(part []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range part {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 0
+	case lower == 0, upper == 1 && unicode.IsUpper(part[0]):
+		// ALL CAPS or Capitalized
+		return 1
+	}
+
+	variations := 0.0
+	for i := 1; i <= min(upper, lower); i++ {
+		variations += binomial(upper+lower, i)
+	}
+	return math.Log2(variations)
+}
+
+func binomial(n, k int) float64 {
+	res := 1.0
+	for i := 1; i <= k; i++ {
+		res = res * float64(n-k+i) / float64(i)
+	}
+	return res
+}
+
+// keyboardRows is the unshifted US QWERTY layout, every row is shifted half a key to the right.
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+const shiftedKeys = `~!@#$%^&*()_+{}|:"<>?`
+const unshiftedKeys = "`1234567890-=[]\\;',./"
+
+type keyPos struct{ row, col int }
+
+var keyPositions = func() map[rune]keyPos {
+	res := make(map[rune]keyPos)
+	for row, keys := range keyboardRows {
+		for col, r := range keys {
+			res[r] = keyPos{row, col}
+		}
+	}
+	return res
+}()
+
+func unshift(r rune) rune {
+	if i := strings.IndexRune(shiftedKeys, r); i >= 0 {
+		return []rune(unshiftedKeys)[i]
+	}
+	return unicode.ToLower(r)
+}
+
+// adjacentKeys reports if the keys are neighbours on the keyboard.
+func adjacentKeys(a, b keyPos) bool {
+	switch b.row - a.row {
+	case 0:
+		return b.col-a.col == 1 || a.col-b.col == 1
+	case 1:
+		return b.col == a.col || b.col == a.col-1
+	case -1:
+		return b.col == a.col || b.col == a.col+1
+	}
+	return false
+}
+
+// keyboardMatch matches walks over neighbour keys like qwerty, 1qaz or zxcvb.
+func keyboardMatch(part []rune) *match {
+	turns, prevDir := 0, keyPos{}
+	for i := 1; i < len(part); i++ {
+		a, okA := keyPositions[unshift(part[i-1])]
+		b, okB := keyPositions[unshift(part[i])]
+		if !okA || !okB || !adjacentKeys(a, b) {
+			return nil
+		}
+		dir := keyPos{b.row - a.row, b.col - a.col}
+		if i > 1 && dir != prevDir {
+			turns++
+		}
+		prevDir = dir
+	}
+
+	bits := math.Log2(keyboardKeys) + math.Log2(float64(len(part))) + float64(turns*turnBits) + capitalizationBits(part)
+	return &match{bits: bits, warning: "keyboard pattern"}
+}
+
+// repeatMatch matches repeated characters or chunks like aaaa or abcabc.
+func repeatMatch(part []rune) *match {
+	for size := 1; size <= len(part)/2; size++ {
+		if len(part)%size != 0 {
+			continue
+		}
+		chunk := string(part[:size])
+		if strings.Repeat(chunk, len(part)/size) != string(part) {
+			continue
+		}
+
+		// the chunk itself is guessed by brute force or as a pattern
+		chunkBits := float64(size) * math.Log2(float64(cardinality(part[:size])))
+		if size >= minPatternLen {
+			if m := bestMatch(part[:size]); m != nil && m.bits < chunkBits {
+				chunkBits = m.bits
+			}
+		}
+		return &match{bits: chunkBits + math.Log2(float64(len(part)/size)), warning: "repeated characters"}
+	}
+	return nil
+}
+
+// sequenceMatch matches runs like abcd, 1234 or 9753.
+func sequenceMatch(part []rune) *match {
+	delta := part[1] - part[0]
+	if delta == 0 || delta > 5 || delta < -5 {
+		return nil
+	}
+	for i := 2; i < len(part); i++ {
+		if part[i]-part[i-1] != delta {
+			return nil
+		}
+	}
+
+	var start float64
+	switch r := unicode.ToLower(part[0]); {
+	case r == 'a' || r == 'z' || r == '0' || r == '1' || r == '9':
+		start = 2
+	case unicode.IsDigit(r):
+		start = math.Log2(10)
+	case unicode.IsLetter(r):
+		start = math.Log2(26)
+	default:
+		start = math.Log2(33)
+	}
+	// the direction and the step
+	bits := start + math.Log2(float64(len(part))) + 1
+	if delta != 1 && delta != -1 {
+		bits += 2
+	}
+	return &match{bits: bits, warning: "sequence"}
+}
+
+// dateMatch matches years and dates like 1987, 120587, 12.05.1987 or 1987-05-12.
+func dateMatch(part []rune) *match {
+	s := string(part)
+	separated := false
+	for _, sep := range []string{"-", "/", ".", "_", " "} {
+		if strings.Count(s, sep) == 2 {
+			s = strings.ReplaceAll(s, sep, "")
+			separated = true
+			break
+		}
+	}
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return nil
+		}
+	}
+
+	switch {
+	case len(s) == 4 && !separated && isYear(s):
+		return &match{bits: math.Log2(dateYears), warning: "date"}
+	case len(s) == 6 || len(s) == 8:
+		if !isDate(s) {
+			return nil
+		}
+		bits := math.Log2(dateYears * 365)
+		if separated {
+			bits += 2
+		}
+		return &match{bits: bits, warning: "date"}
+	}
+	return nil
+}
+
+// isYear reports if the 4 digits are a year from 1900 to 2029.
+func isYear(s string) bool {
+	return strings.HasPrefix(s, "19") || strings.HasPrefix(s, "20") && s[2] <= '2'
+}
+
+// isDate reports if the digits are day, month and year in any common order, the year has 2 or 4 digits.
+func isDate(s string) bool {
+	num := func(s string) int {
+		n := 0
+		for _, r := range s {
+			n = n*10 + int(r-'0')
+		}
+		return n
+	}
+	dayMonth := func(a, b int) bool {
+		return a >= 1 && a <= 31 && b >= 1 && b <= 12 || b >= 1 && b <= 31 && a >= 1 && a <= 12
+	}
+
+	if len(s) == 8 {
+		return isYear(s[4:]) && dayMonth(num(s[:2]), num(s[2:4])) || isYear(s[:4]) && dayMonth(num(s[4:6]), num(s[6:]))
+	}
+	return dayMonth(num(s[:2]), num(s[2:4])) || dayMonth(num(s[2:4]), num(s[4:]))
+}
+
+// cardinality returns the size of the alphabet of character classes present in the password.
+func cardinality(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	res := 0
+	for _, c := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if c.present {
+			res += c.size
+		}
+	}
+	return res
+}
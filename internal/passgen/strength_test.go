@@ -0,0 +1,54 @@
+package passgen
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateStrengthPatterns(t *testing.T) {
+	tests := []struct {
+		pass    string
+		warning string
+	}{
+		{"password", "common password"},
+		{"qwertyuiop", "common password"},
+		{"zxcvbnm,./", "keyboard pattern"},
+		{"aaaaaaaaaaaa", "repeated characters"},
+		{"abcdefgh", "sequence"},
+		{"12.05.1987", "date"},
+	}
+	for _, tt := range tests {
+		s := EstimateStrength(tt.pass)
+		if s.Entropy >= WeakEntropy || len(s.Warnings) == 0 || s.Warnings[0] != tt.warning {
+			t.Errorf("%q: %.1f bits, warnings %v, want a weak %s", tt.pass, s.Entropy, s.Warnings, tt.warning)
+		}
+	}
+}
+
+// TestEstimateStrengthLong checks long values like keys are estimated in linear time.
+func TestEstimateStrengthLong(t *testing.T) {
+	raw := make([]byte, 3072)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(raw)
+
+	start := time.Now()
+	s := EstimateStrength(key)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("a %d byte value took %v", len(key), elapsed)
+	}
+	if s.Entropy < StrongEntropy {
+		t.Errorf("a random %d byte value has %.1f bits", len(key), s.Entropy)
+	}
+
+	// repeats longer than the pattern limit are split, but still far cheaper than brute force
+	repeated := strings.Repeat("a", 4096)
+	if s := EstimateStrength(repeated); s.Entropy >= float64(len(repeated))*math.Log2(26)/10 {
+		t.Errorf("%d repeated characters have %.1f bits", len(repeated), s.Entropy)
+	}
+}
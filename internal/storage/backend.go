@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Backend drivers selectable with Config.Backend.
+const (
+	BackendGit = "git"
+	BackendDir = "dir"
+)
+
+// vaultFiles are the files a backend keeps for the vault.
+var vaultFiles = []string{dataFileName, kdfFileName}
+
+const (
+	lockRetryInterval = 100 * time.Millisecond
+	lockTimeout       = 10 * time.Second
+	// staleLockAge is the age of a lock file left by a crashed process.
+	staleLockAge = 5 * time.Minute
+)
+
+// Backend keeps the vault files (data.dat and kdf.json) and their history.
+type Backend interface {
+	// Load returns the vault files at the given revision, the latest one if rev is empty.
+	// A backend without data returns an empty snapshot.
+	Load(rev string) (*Snapshot, error)
+	// Save stores the files as a new revision on top of base. If the backend got other
+	// revisions after base, merge is called to combine them with the saved files.
+	// A nil merge means the files can't be merged or kept pending: Save fails with ErrRemoteChanged
+	// if there are other revisions and with ErrOffline if the remote is unreachable.
+	Save(base string, files map[string][]byte, message string, merge MergeFunc) (*Snapshot, error)
+	// History returns revisions which changed the file, the newest first.
+	History(file string) ([]Revision, error)
+	// Lock prevents concurrent changes from other passy processes until unlock is called.
+	Lock() (unlock func() error, err error)
+}
+
+// Syncer is implemented by backends which can save revisions locally and push them later.
+type Syncer interface {
+	// Pending returns the number of revisions which are not pushed yet.
+	Pending() (int, error)
+	// Sync pushes pending revisions, merging them with remote ones if needed.
+	Sync(merge MergeFunc) (int, error)
+}
+
+// Snapshot is the vault files at some revision.
+type Snapshot struct {
+	Files map[string][]byte
+	Rev   string
+	// Offline is set when the backend couldn't reach the remote and used its local copy.
+	Offline bool
+	// PushErr is why a saved revision wasn't pushed to the remote, it's kept locally until Sync then.
+	PushErr error
+}
+
+// Revision describes a saved version of the vault.
+type Revision struct {
+	ID      string
+	Time    time.Time
+	Author  string
+	Message string
+}
+
+// MergeFunc combines the saved files (ours) with the files saved by someone else (theirs)
+// since the common base revision.
+type MergeFunc func(base, ours, theirs map[string][]byte) (map[string][]byte, error)
+
+func newBackend(cfg *Config) (Backend, error) {
+	switch cfg.Backend {
+	case "", BackendGit:
+		return newGitBackend(cfg.GitRepoPath), nil
+	case BackendDir:
+		return newDirBackend(cfg.DirPath), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+// lockFile creates the lock file exclusively, waiting for another process to release it.
+func lockFile(path string) (func() error, error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() error { return os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file: %v", err)
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			_ = os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the vault is locked by another passy process, remove %s if it's not running", path)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 
 	"github.com/BurntSushi/toml"
@@ -19,10 +18,37 @@ GitRepoPath = "https://github.com/user/repository.git"`
 type Config struct {
 	PrivKeyPath string
 	GitRepoPath string
+	// UsePassphrase derives the vault key from a master passphrase instead of reading PrivKeyPath.
+	UsePassphrase bool
+	// IdentityPath is the X25519 private key for a vault shared with several recipients.
+	IdentityPath string `toml:",omitempty"`
+	// Backend is the storage driver: "git" (default) keeps the vault in GitRepoPath,
+	// "dir" keeps it in the local directory DirPath.
+	Backend string `toml:",omitempty"`
+	DirPath string `toml:",omitempty"`
+	// MinEntropy is the password strength in bits below which passy warns on adding a password, 60 if not set.
+	MinEntropy float64 `toml:",omitempty"`
+	// BreachFile is a local Have I Been Pwned SHA-1 list, added passwords are looked up in it if set.
+	BreachFile string `toml:",omitempty"`
 }
 
 // ParseConfig reads the config file, fills config fields, and validates them.
 func ParseConfig() (*Config, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the config fields
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// LoadConfig reads the config file without validating it.
+func LoadConfig() (*Config, error) {
 	var config Config
 
 	// Expand the default config file path
@@ -41,12 +67,26 @@ func ParseConfig() (*Config, error) {
 		return nil, fmt.Errorf("error reading config file: %v", err)
 	}
 
-	// Validate the config fields
-	if err := validateConfig(&config); err != nil {
-		return nil, err
+	return &config, nil
+}
+
+// Save writes the config back to the config file.
+func (c *Config) Save() error {
+	configFilePath, err := expandPath(defaultConfigFile)
+	if err != nil {
+		return fmt.Errorf("error expanding config file path: %v", err)
 	}
 
-	return &config, nil
+	f, err := os.Create(configFilePath)
+	if err != nil {
+		return fmt.Errorf("error opening config file: %v", err)
+	}
+	defer f.Close()
+
+	if err := toml.NewEncoder(f).Encode(c); err != nil {
+		return fmt.Errorf("error writing config file: %v", err)
+	}
+	return nil
 }
 
 func checkConfigExistOrCreateNew(configFilePath string) (err error) {
@@ -82,16 +122,31 @@ func expandPath(path string) (string, error) {
 	return filepath.Join(homeDir, path[2:]), nil
 }
 
-// validateConfig checks if the paths are valid and if the Git repository is valid.
-func validateConfig(config *Config) error {
-	// Validate PrivKeyPath
-	if err := validateFileExists(config.PrivKeyPath); err != nil {
-		return fmt.Errorf("invalid private key path: %v", err)
+// Validate checks if the paths are valid and if the Git repository is valid.
+func (c *Config) Validate() error {
+	// Validate PrivKeyPath, it's not used when the key is derived from a passphrase or an identity
+	if c.IdentityPath != "" {
+		if err := validateFileExists(c.IdentityPath); err != nil {
+			return fmt.Errorf("invalid identity path: %v", err)
+		}
+	} else if !c.UsePassphrase {
+		if err := validateFileExists(c.PrivKeyPath); err != nil {
+			return fmt.Errorf("invalid private key path: %v", err)
+		}
 	}
 
-	// Validate GitRepoPath
-	if err := validateGitRepo(config.GitRepoPath); err != nil {
-		return fmt.Errorf("invalid Git repository path: %v", err)
+	switch c.Backend {
+	case "", BackendGit:
+		// Validate GitRepoPath
+		if err := validateGitRepo(c.GitRepoPath); err != nil {
+			return fmt.Errorf("invalid Git repository path: %v", err)
+		}
+	case BackendDir:
+		if c.DirPath == "" {
+			return errors.New("invalid vault directory: DirPath is not set")
+		}
+	default:
+		return fmt.Errorf("unknown storage backend %q", c.Backend)
 	}
 
 	return nil
@@ -105,11 +160,10 @@ func validateFileExists(path string) error {
 	return nil
 }
 
-// validateGitRepo checks if the given path is a valid remote Git repository.
+// validateGitRepo checks if the Git repository is set, it's not reached here so passy works offline.
 func validateGitRepo(repoPath string) error {
-	cmd := exec.Command("git", "ls-remote", repoPath)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("not a valid remote Git repository: %s", repoPath)
+	if repoPath == "" {
+		return errors.New("the repository is not set")
 	}
 	return nil
 }
@@ -3,6 +3,7 @@ package storage
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
@@ -12,23 +13,28 @@ import (
 )
 
 // Decrypt get's data from the repository, decrypts in and unmarshal.
+// An empty repository gives an empty top folder.
 func (s *Storage) Decrypt() (*Folder, error) {
 	err := s.Update()
 	if err != nil {
 		return nil, err
 	}
 
+	if s.Data == "" {
+		return &Folder{Name: "", SubFolder: []*Folder{}}, nil
+	}
+
 	decrypted, err := s.decrypt(s.Data)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to decode pass data")
 	}
 
-	if len(decrypted) < 2 {
-		return nil, errors.Wrap(err, "pass data file is too short")
+	if len(decrypted) < 8 {
+		return nil, errors.Wrap(ErrCorrupted, "pass data file is too short")
 	}
 	startGarbageLen, endGarbageLen := binary.BigEndian.Uint32(decrypted[:4]), binary.BigEndian.Uint32(decrypted[4:8])
-	if len(decrypted) < int(startGarbageLen)+int(endGarbageLen)+8 {
-		return nil, errors.Wrap(err, "pass data file encoded incorrectly")
+	if uint64(len(decrypted)) < uint64(startGarbageLen)+uint64(endGarbageLen)+8 {
+		return nil, errors.Wrap(ErrCorrupted, "pass data file encoded incorrectly")
 	}
 
 	decrypted = decrypted[8+startGarbageLen : len(decrypted)-int(endGarbageLen)]
@@ -46,14 +52,63 @@ func (s *Storage) decrypt(dataStr string) ([]byte, error) {
 		return nil, err
 	}
 
-	// Create a new AES cipher
-	block, err := aes.NewCipher(s.PrivKey)
+	if !isVersioned(data) {
+		return s.decryptLegacy(data)
+	}
+
+	h, rawHeader, data, err := parseHeader(data)
 	if err != nil {
 		return nil, err
 	}
+	switch h.Cipher {
+	case cipherIDAES128GCM, cipherIDAES192GCM, cipherIDAES256GCM:
+	default:
+		return nil, errors.Wrapf(ErrNewerFormat, "unknown cipher %d", h.Cipher)
+	}
+	if h.KDF != s.kdfID() {
+		return nil, fmt.Errorf("the vault is encrypted with a %s, but passy is configured to use a %s",
+			kdfName(h.KDF), kdfName(s.kdfID()))
+	}
+	if h.KDF == kdfIDRecipients {
+		if err := s.unwrapKey(h.Extension); err != nil {
+			return nil, err
+		}
+	}
+	if check := keyCheck(s.PrivKey); !hmac.Equal(h.KeyCheck[:], check[:]) {
+		return nil, ErrWrongKey
+	}
+	id, err := cipherID(s.PrivKey)
+	if err != nil {
+		return nil, err
+	}
+	if id != h.Cipher {
+		return nil, errors.Wrapf(ErrCorrupted, "the header cipher %d doesn't match the %d byte key", h.Cipher, len(s.PrivKey))
+	}
 
-	// GCM mode requires a nonce (number used once)
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := s.gcm()
+	if err != nil {
+		return nil, err
+	}
+
+	// Split the nonce and the ciphertext
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.Wrap(ErrCorrupted, "ciphertext too short")
+	}
+	nonce, cipherText := data[:nonceSize], data[nonceSize:]
+
+	// Decrypt the ciphertext, the key is right so a failure means the data was damaged
+	plainText, err := gcm.Open(nil, nonce, cipherText, rawHeader)
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+
+	return plainText, nil
+}
+
+// decryptLegacy decrypts the unversioned nonce||ciphertext format.
+func (s *Storage) decryptLegacy(data []byte) ([]byte, error) {
+	gcm, err := s.gcm()
 	if err != nil {
 		return nil, err
 	}
@@ -68,8 +123,19 @@ func (s *Storage) decrypt(dataStr string) ([]byte, error) {
 	// Decrypt the ciphertext
 	plainText, err := gcm.Open(nil, nonce, cipherText, nil)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "wrong key or corrupted file")
 	}
 
 	return plainText, nil
 }
+
+func (s *Storage) gcm() (cipher.AEAD, error) {
+	// Create a new AES cipher
+	block, err := aes.NewCipher(s.PrivKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// GCM mode requires a nonce (number used once)
+	return cipher.NewGCM(block)
+}
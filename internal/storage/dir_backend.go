@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dirBackend keeps the vault in a plain local directory, e.g. on a mounted drive or in a synced folder:
+//
+//	HEAD                  number of the current revision
+//	revisions/<n>/        vault files of the revision n
+//	revisions/<n>/meta.json
+const (
+	dirHeadFile      = "HEAD"
+	dirRevisionsDir  = "revisions"
+	dirMetaFile      = "meta.json"
+	dirLockFile      = ".lock"
+	dirFilePerm      = 0o600
+	dirDirectoryPerm = 0o700
+)
+
+type dirBackend struct {
+	path string
+}
+
+func newDirBackend(path string) *dirBackend {
+	return &dirBackend{path: path}
+}
+
+// Load returns the vault files at the given revision, the latest one if rev is empty.
+func (b *dirBackend) Load(rev string) (*Snapshot, error) {
+	if rev == "" {
+		head, err := b.head()
+		if err != nil {
+			return nil, err
+		}
+		if head == 0 {
+			return &Snapshot{Files: map[string][]byte{}}, nil
+		}
+		rev = strconv.Itoa(head)
+	}
+
+	n, err := strconv.Atoi(rev)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("unknown revision %q", rev)
+	}
+
+	files := make(map[string][]byte)
+	for _, name := range vaultFiles {
+		data, err := os.ReadFile(filepath.Join(b.revisionPath(n), name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("error reading %s: %v", name, err)
+		}
+		files[name] = data
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("unknown revision %q", rev)
+	}
+	return &Snapshot{Files: files, Rev: rev}, nil
+}
+
+// Save writes the files as the next revision, merging them with revisions saved after base.
+func (b *dirBackend) Save(base string, files map[string][]byte, message string, merge MergeFunc) (*Snapshot, error) {
+	head, err := b.head()
+	if err != nil {
+		return nil, err
+	}
+
+	if current := revisionID(head); current != base {
+		if merge == nil {
+			return nil, ErrRemoteChanged
+		}
+		baseFiles := map[string][]byte{}
+		if base != "" {
+			baseSnapshot, err := b.Load(base)
+			if err != nil {
+				return nil, err
+			}
+			baseFiles = baseSnapshot.Files
+		}
+		theirs, err := b.Load(current)
+		if err != nil {
+			return nil, err
+		}
+		if files, err = merge(baseFiles, files, theirs.Files); err != nil {
+			return nil, err
+		}
+	}
+
+	// the revision is written to a temporary directory first, so HEAD never points to a partial one
+	next := head + 1
+	tmp := b.revisionPath(next) + ".tmp"
+	// leftovers of an interrupted save are not referenced by HEAD
+	for _, path := range []string{tmp, b.revisionPath(next)} {
+		if err := os.RemoveAll(path); err != nil {
+			return nil, fmt.Errorf("failed to clean up %s: %v", path, err)
+		}
+	}
+	if err := os.MkdirAll(tmp, dirDirectoryPerm); err != nil {
+		return nil, fmt.Errorf("failed to create revision directory: %v", err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tmp, name), data, dirFilePerm); err != nil {
+			return nil, fmt.Errorf("error writing %s: %v", name, err)
+		}
+	}
+
+	meta, err := json.MarshalIndent(Revision{
+		ID:      strconv.Itoa(next),
+		Time:    time.Now(),
+		Author:  currentAuthor(),
+		Message: message,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, dirMetaFile), meta, dirFilePerm); err != nil {
+		return nil, fmt.Errorf("error writing revision metadata: %v", err)
+	}
+	if err := os.Rename(tmp, b.revisionPath(next)); err != nil {
+		return nil, fmt.Errorf("failed to save revision: %v", err)
+	}
+	if err := b.setHead(next); err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{Files: files, Rev: strconv.Itoa(next)}, nil
+}
+
+// History returns revisions which changed the file.
+func (b *dirBackend) History(file string) ([]Revision, error) {
+	head, err := b.head()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		revs []Revision
+		prev []byte
+	)
+	for n := 1; n <= head; n++ {
+		data, err := os.ReadFile(filepath.Join(b.revisionPath(n), file))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading %s: %v", file, err)
+		}
+		if n > 1 && string(data) == string(prev) {
+			continue
+		}
+		prev = data
+
+		meta, err := os.ReadFile(filepath.Join(b.revisionPath(n), dirMetaFile))
+		if err != nil {
+			return nil, fmt.Errorf("error reading revision metadata: %v", err)
+		}
+		var rev Revision
+		if err := json.Unmarshal(meta, &rev); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal revision metadata: %v", err)
+		}
+		revs = append(revs, rev)
+	}
+
+	// the newest first
+	for i, j := 0, len(revs)-1; i < j; i, j = i+1, j-1 {
+		revs[i], revs[j] = revs[j], revs[i]
+	}
+	return revs, nil
+}
+
+// Lock locks the vault directory.
+func (b *dirBackend) Lock() (func() error, error) {
+	if err := os.MkdirAll(b.path, dirDirectoryPerm); err != nil {
+		return nil, fmt.Errorf("failed to create vault directory: %v", err)
+	}
+	return lockFile(filepath.Join(b.path, dirLockFile))
+}
+
+func (b *dirBackend) revisionPath(n int) string {
+	return filepath.Join(b.path, dirRevisionsDir, strconv.Itoa(n))
+}
+
+// head returns the current revision number, 0 if there are no revisions yet.
+func (b *dirBackend) head() (int, error) {
+	data, err := os.ReadFile(filepath.Join(b.path, dirHeadFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error reading %s: %v", dirHeadFile, err)
+	}
+	head, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", dirHeadFile, err)
+	}
+	return head, nil
+}
+
+func (b *dirBackend) setHead(n int) error {
+	path := filepath.Join(b.path, dirHeadFile)
+	if err := os.WriteFile(path+".tmp", []byte(strconv.Itoa(n)+"\n"), dirFilePerm); err != nil {
+		return fmt.Errorf("error writing %s: %v", dirHeadFile, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("error writing %s: %v", dirHeadFile, err)
+	}
+	return nil
+}
+
+// revisionID returns the revision id of the revision number, empty for no revision.
+func revisionID(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// currentAuthor returns user@host of the current user.
+func currentAuthor() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
@@ -1,8 +1,6 @@
 package storage
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
 	"encoding/binary"
 	"encoding/json"
@@ -16,6 +14,17 @@ const defaultCommitMessage = "nothing important"
 
 // Encrypt encrypts data inside of a Storage.
 func (s *Storage) Encrypt(topFolder *Folder) error {
+	if err := s.refreshKDF(); err != nil {
+		return err
+	}
+	if err := s.ensureContentKey(); err != nil {
+		return err
+	}
+	return s.seal(topFolder)
+}
+
+// seal encrypts the folders with the key material already in the storage, it never generates a new key.
+func (s *Storage) seal(topFolder *Folder) error {
 	byteData, err := json.Marshal(topFolder)
 	if err != nil {
 		return errors.Wrap(err, "failed to marshal new password data")
@@ -53,15 +62,29 @@ func (s *Storage) Encrypt(topFolder *Folder) error {
 	return nil
 }
 
+// refreshKDF generates new KDF parameters and re-derives the key if the storage uses a passphrase
+// and the vault has no parameters yet or they are weaker than the current defaults.
+func (s *Storage) refreshKDF() error {
+	if s.passphrase == nil || (s.KDF != nil && !s.KDF.outdated()) {
+		return nil
+	}
+
+	params, err := newKDFParams()
+	if err != nil {
+		return err
+	}
+	s.KDF = params
+	s.PrivKey = params.deriveKey(s.passphrase)
+	return nil
+}
+
 func (s *Storage) encrypt(data []byte) ([]byte, error) {
-	// Create a new AES cipher
-	block, err := aes.NewCipher(s.PrivKey)
+	gcm, err := s.gcm()
 	if err != nil {
 		return nil, err
 	}
 
-	// GCM mode requires a nonce (number used once)
-	gcm, err := cipher.NewGCM(block)
+	id, err := cipherID(s.PrivKey)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +94,31 @@ func (s *Storage) encrypt(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	// Encrypt the plaintext
-	cipherText := gcm.Seal(nonce, nonce, data, nil)
-	return []byte(cipherText), nil
+	h := header{
+		Version:  formatVersion,
+		KDF:      s.kdfID(),
+		Cipher:   id,
+		KeyCheck: keyCheck(s.PrivKey),
+	}
+	if s.UsesRecipients() {
+		if h.Extension, err = s.wrapKey(); err != nil {
+			return nil, err
+		}
+	}
+	rawHeader := h.marshal()
+
+	// Encrypt the plaintext, the header is authenticated as additional data
+	cipherText := gcm.Seal(append(rawHeader, nonce...), nonce, data, rawHeader)
+	return cipherText, nil
+}
+
+func (s *Storage) kdfID() byte {
+	switch {
+	case s.UsesRecipients():
+		return kdfIDRecipients
+	case s.KDF != nil:
+		return kdfIDArgon2id
+	default:
+		return kdfIDNone
+	}
 }
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names of the built-in entry fields, custom fields can't use them.
+const (
+	FieldPass     = "pass"
+	FieldUsername = "username"
+	FieldURL      = "url"
+	FieldNotes    = "notes"
+	FieldOTP      = "otp"
+)
+
+// Field is a custom named value of an entry, e.g. recovery codes or a PIN.
+// Secret fields are hidden the same way passwords are.
+type Field struct {
+	Name   string
+	Value  string
+	Secret bool `json:",omitempty"`
+}
+
+// Field returns the value of a built-in or custom field.
+func (f *Folder) Field(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case FieldPass:
+		return f.Pass, f.Pass != ""
+	case FieldUsername:
+		return f.Username, f.Username != ""
+	case FieldURL:
+		return f.URL, f.URL != ""
+	case FieldNotes:
+		return f.Notes, f.Notes != ""
+	case FieldOTP:
+		return f.OTP, f.OTP != ""
+	}
+	for _, field := range f.Fields {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+// IsBuiltinField reports whether the name, in any case, is one of the built-in fields.
+func IsBuiltinField(name string) bool {
+	switch strings.ToLower(name) {
+	case FieldPass, FieldUsername, FieldURL, FieldNotes, FieldOTP:
+		return true
+	}
+	return false
+}
+
+// SetField sets the custom field, an empty value removes it.
+func (f *Folder) SetField(name, value string, secret bool) error {
+	if name == "" {
+		return fmt.Errorf("empty field name")
+	}
+	if IsBuiltinField(name) {
+		return fmt.Errorf("%q is a built-in field", name)
+	}
+
+	for i, field := range f.Fields {
+		if field.Name != name {
+			continue
+		}
+		if value == "" {
+			f.Fields = append(f.Fields[:i], f.Fields[i+1:]...)
+		} else {
+			f.Fields[i] = Field{Name: name, Value: value, Secret: secret}
+		}
+		return nil
+	}
+	if value != "" {
+		f.Fields = append(f.Fields, Field{Name: name, Value: value, Secret: secret})
+	}
+	return nil
+}
+
+// details returns the entry fields in the display order, values are replaced with hidden if it's not empty:
+// notes often keep recovery codes and PINs, so only field names are shown without --show-all.
+func (f *Folder) details(hidden string) [][2]string {
+	var res [][2]string
+	add := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if hidden != "" {
+			value = hidden
+		}
+		res = append(res, [2]string{name, value})
+	}
+
+	add("Username", f.Username)
+	add("URL", f.URL)
+	add("Notes", f.Notes)
+	add("OTP", f.OTP)
+	for _, field := range f.Fields {
+		add(field.Name, field.Value)
+	}
+	return res
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Pattern matching modes of Find.
+const (
+	MatchSubstring = "substring"
+	MatchGlob      = "glob"
+	MatchRegex     = "regex"
+	MatchFuzzy     = "fuzzy"
+)
+
+// Find returns keys of entries matching the pattern by their full key, username or URL.
+// Fuzzy matches are sorted by relevance, the others are in the tree order.
+func (f *Folder) Find(pattern, mode string) ([]string, error) {
+	match, err := matcher(pattern, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		key   string
+		score int
+	}
+	var results []result
+	walkEntries(f, "", func(key string, entry *Folder) {
+		best, found := 0, false
+		for _, s := range []string{key, entry.Username, entry.URL} {
+			if s == "" {
+				continue
+			}
+			if score, ok := match(s); ok && (!found || score > best) {
+				best, found = score, true
+			}
+		}
+		if found {
+			results = append(results, result{key: key, score: best})
+		}
+	})
+
+	if mode == MatchFuzzy {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].score > results[j].score
+		})
+	}
+
+	keys := make([]string, 0, len(results))
+	for _, r := range results {
+		keys = append(keys, r.key)
+	}
+	return keys, nil
+}
+
+// matcher returns the function matching a string with the pattern and scoring the match.
+func matcher(pattern, mode string) (func(s string) (int, bool), error) {
+	switch mode {
+	case "", MatchSubstring:
+		pattern = strings.ToLower(pattern)
+		return func(s string) (int, bool) {
+			return 0, strings.Contains(strings.ToLower(s), pattern)
+		}, nil
+	case MatchGlob, MatchRegex:
+		expr := pattern
+		if mode == MatchGlob {
+			expr = "(?i)^" + globToRegexp(pattern) + "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s pattern", mode)
+		}
+		return func(s string) (int, bool) {
+			return 0, re.MatchString(s)
+		}, nil
+	case MatchFuzzy:
+		pattern = strings.ToLower(pattern)
+		return func(s string) (int, bool) {
+			return fuzzyScore(strings.ToLower(s), pattern)
+		}, nil
+	default:
+		return nil, errors.Errorf("unknown match mode %q", mode)
+	}
+}
+
+// globToRegexp converts a glob to a regular expression: * matches anything but the key separator,
+// ** matches across folders, ? matches a single character and [...] is a character class.
+func globToRegexp(glob string) string {
+	sb := &strings.Builder{}
+	for i := 0; i < len(glob); {
+		r, size := utf8.DecodeRuneInString(glob[i:])
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			size = 2
+		case r == '*':
+			sb.WriteString("[^" + folderSeparator + "]*")
+		case r == '?':
+			sb.WriteString("[^" + folderSeparator + "]")
+		case r == '[':
+			if end := strings.IndexByte(glob[i+1:], ']'); end >= 0 {
+				class := glob[i+1 : i+1+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				sb.WriteString("[" + class + "]")
+				size = end + 2
+			} else {
+				sb.WriteString(regexp.QuoteMeta("["))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+		i += size
+	}
+	return sb.String()
+}
+
+// fuzzyScore matches the pattern characters in order, the score is higher for consecutive characters,
+// matches at the start of key segments or words and shorter strings.
+func fuzzyScore(s, pattern string) (int, bool) {
+	const (
+		matchBonus       = 16
+		consecutiveBonus = 24
+		boundaryBonus    = 32
+	)
+
+	pr := []rune(pattern)
+	if len(pr) == 0 {
+		return 0, true
+	}
+
+	score, pi := 0, 0
+	prev, prevMatched := rune(0), false
+	for i, r := range []rune(s) {
+		if pi < len(pr) && r == pr[pi] {
+			score += matchBonus
+			if prevMatched {
+				score += consecutiveBonus
+			}
+			if i == 0 || strings.ContainsRune(folderSeparator+"._- @:", prev) {
+				score += boundaryBonus
+			}
+			pi++
+			prevMatched = true
+		} else {
+			prevMatched = false
+		}
+		prev = r
+	}
+	if pi < len(pr) {
+		return 0, false
+	}
+	return score - utf8.RuneCountInString(s), true
+}
@@ -3,12 +3,23 @@ package storage
 import (
 	"errors"
 	"strings"
+	"time"
 )
 
 type Folder struct {
 	Name      string
 	SubFolder []*Folder
 	Pass      string
+	// History keeps previous passwords, the latest first.
+	History  []PassRecord `json:",omitempty"`
+	Username string       `json:",omitempty"`
+	URL      string       `json:",omitempty"`
+	Notes    string       `json:",omitempty"`
+	Fields   []Field      `json:",omitempty"`
+	// OTP is the otpauth:// URI of the TOTP secret.
+	OTP string `json:",omitempty"`
+	// Changed is when the password was set, nil for passwords set by older versions.
+	Changed *time.Time `json:",omitempty"`
 }
 
 func (f *Folder) String(prefix string) func() string {
@@ -29,6 +40,10 @@ func (f *Folder) String(prefix string) func() string {
 		sb.WriteString(prefix + tab + passwordColor + "Password: " + resetColor + f.Pass + "\n")
 	}
 
+	for _, d := range f.details("") {
+		sb.WriteString(prefix + tab + passwordColor + d[0] + ": " + resetColor + indentValue(d[1], prefix+tab+tab) + "\n")
+	}
+
 	if f.SubFolder != nil {
 		for _, sf := range f.SubFolder {
 			newPrefix := prefix
@@ -49,6 +64,7 @@ func (f *Folder) SecureString(prefix string) func() string {
 	const lockSymbol = "🔒"
 	const folderColor = "\033[1;34m"   // Blue color for folder names
 	const passwordColor = "\033[1;31m" // Red color for passwords
+	const fieldColor = "\033[1;33m"    // Yellow color for other fields
 	const resetColor = "\033[0m"       // Reset color
 
 	sb := &strings.Builder{}
@@ -61,6 +77,10 @@ func (f *Folder) SecureString(prefix string) func() string {
 		sb.WriteString(prefix + tab + passwordColor + "Password: " + lockSymbol + resetColor + "\n")
 	}
 
+	for _, d := range f.details(lockSymbol) {
+		sb.WriteString(prefix + tab + fieldColor + d[0] + ": " + resetColor + indentValue(d[1], prefix+tab+tab) + "\n")
+	}
+
 	if f.SubFolder != nil {
 		for _, sf := range f.SubFolder {
 			newPrefix := prefix
@@ -74,11 +94,27 @@ func (f *Folder) SecureString(prefix string) func() string {
 	return sb.String
 }
 
+// indentValue indents continuation lines of multiline values.
+func indentValue(value, indent string) string {
+	return strings.ReplaceAll(value, "\n", "\n"+indent)
+}
+
 const folderSeparator = "/"
 
 func (f *Folder) Add(folderPath, pass string) error {
+	cf, err := f.Create(folderPath)
+	if err != nil {
+		return err
+	}
+
+	cf.setPass(pass)
+	return nil
+}
+
+// Create returns the folder on the path, missing folders are created.
+func (f *Folder) Create(folderPath string) (*Folder, error) {
 	if f.Name != "" {
-		return errors.New("should add to the head only")
+		return nil, errors.New("should add to the head only")
 	}
 
 	path := strings.Split(folderPath, folderSeparator)
@@ -109,13 +145,13 @@ func (f *Folder) Add(folderPath, pass string) error {
 		}
 	}
 
-	cf.Pass = pass
-	return nil
+	return cf, nil
 }
 
+// Delete removes the key with all its subkeys, it's called on the root folder the same way Add is.
 func (f *Folder) Delete(folderPath string) error {
-	if f.Name == "" {
-		return errors.New("cannot delete from an empty folder")
+	if f.Name != "" {
+		return errors.New("should delete from the head only")
 	}
 
 	path := strings.Split(folderPath, folderSeparator)
@@ -172,3 +208,28 @@ func (f *Folder) GetSubFolder(key string) (*Folder, bool) {
 
 	return cf, true
 }
+
+// Keys returns full keys of all entries in the tree order.
+func (f *Folder) Keys() []string {
+	return entryKeys(f)
+}
+
+// EmptyFolders returns full keys of folders without values and subfolders.
+func (f *Folder) EmptyFolders() []string {
+	var keys []string
+	var walk func(f *Folder, prefix string)
+	walk = func(f *Folder, prefix string) {
+		for _, sf := range f.SubFolder {
+			key := sf.Name
+			if prefix != "" {
+				key = prefix + folderSeparator + sf.Name
+			}
+			if len(sf.SubFolder) == 0 && sf.entry() == nil {
+				keys = append(keys, key)
+			}
+			walk(sf, key)
+		}
+	}
+	walk(f, "")
+	return keys
+}
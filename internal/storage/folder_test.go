@@ -0,0 +1,30 @@
+package storage
+
+import "testing"
+
+func TestDelete(t *testing.T) {
+	root := &Folder{}
+	for _, key := range []string{"mail", "web/github", "web/gitlab"} {
+		if err := root.Add(key, "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := root.Delete("web/github"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := root.GetSubFolder("web/github"); ok {
+		t.Error("web/github is not deleted")
+	}
+	if _, ok := root.GetSubFolder("web/gitlab"); !ok {
+		t.Error("web/gitlab is deleted")
+	}
+
+	if err := root.Delete("web/github"); err == nil {
+		t.Error("a missing key is deleted")
+	}
+	web, _ := root.GetSubFolder("web")
+	if err := web.Delete("gitlab"); err == nil {
+		t.Error("a key is deleted from a subfolder")
+	}
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// data.dat layout:
+//
+//	magic "PASSY" | version (1 byte) | kdf id (1 byte) | cipher id (1 byte) |
+//	key check (8 bytes) | extension length (4 bytes, big endian) | extension |
+//	nonce | AES-GCM ciphertext
+//
+// Everything before the nonce is the header, it's authenticated as GCM additional data.
+// Files without the magic are the legacy bare nonce||ciphertext format.
+const (
+	formatMagic   = "PASSY"
+	formatVersion = 1
+
+	headerFixedLen = len(formatMagic) + 3 + keyCheckLen + 4
+	keyCheckLen    = 8
+)
+
+// KDF identifiers stored in the header.
+const (
+	kdfIDNone     byte = 0 // raw key file
+	kdfIDArgon2id byte = 1
+	// the content key is wrapped for recipients in the header extension
+	kdfIDRecipients byte = 2
+)
+
+// Cipher identifiers stored in the header, AES-GCM with the length of the vault key.
+const (
+	cipherIDAES256GCM byte = 1
+	cipherIDAES128GCM byte = 2
+	cipherIDAES192GCM byte = 3
+)
+
+var (
+	ErrWrongKey     = errors.New("wrong key or passphrase")
+	ErrCorrupted    = errors.New("vault data is corrupted")
+	ErrNewerFormat  = errors.New("vault was written by a newer version of passy, please update")
+	ErrNotPassyFile = errors.New("not a passy vault file")
+)
+
+type header struct {
+	Version   byte
+	KDF       byte
+	Cipher    byte
+	KeyCheck  [keyCheckLen]byte
+	Extension []byte
+}
+
+func (h *header) marshal() []byte {
+	buf := make([]byte, 0, headerFixedLen+len(h.Extension))
+	buf = append(buf, formatMagic...)
+	buf = append(buf, h.Version, h.KDF, h.Cipher)
+	buf = append(buf, h.KeyCheck[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(h.Extension)))
+	return append(buf, h.Extension...)
+}
+
+// isVersioned reports if the data starts with the format magic.
+func isVersioned(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(formatMagic))
+}
+
+// parseHeader parses the header and returns it with its raw bytes and the rest of the data.
+func parseHeader(data []byte) (h *header, raw, rest []byte, err error) {
+	if !isVersioned(data) {
+		return nil, nil, nil, ErrNotPassyFile
+	}
+	if len(data) < headerFixedLen {
+		return nil, nil, nil, errors.Wrap(ErrCorrupted, "header is truncated")
+	}
+
+	h = &header{}
+	pos := len(formatMagic)
+	h.Version, h.KDF, h.Cipher = data[pos], data[pos+1], data[pos+2]
+	if h.Version > formatVersion {
+		return nil, nil, nil, ErrNewerFormat
+	}
+	if h.Version == 0 {
+		return nil, nil, nil, errors.Wrap(ErrCorrupted, "invalid format version")
+	}
+	pos += 3
+	copy(h.KeyCheck[:], data[pos:pos+keyCheckLen])
+	pos += keyCheckLen
+
+	extLen := binary.BigEndian.Uint32(data[pos : pos+4])
+	pos += 4
+	if uint64(len(data)-pos) < uint64(extLen) {
+		return nil, nil, nil, errors.Wrap(ErrCorrupted, "header extension is truncated")
+	}
+	h.Extension = data[pos : pos+int(extLen)]
+	pos += int(extLen)
+
+	return h, data[:pos], data[pos:], nil
+}
+
+// keyCheck returns a short key fingerprint, it tells a wrong key from a corrupted file.
+func keyCheck(key []byte) [keyCheckLen]byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("passy key check"))
+	var check [keyCheckLen]byte
+	copy(check[:], mac.Sum(nil))
+	return check
+}
+
+// cipherID returns the identifier of AES-GCM with the key length.
+func cipherID(key []byte) (byte, error) {
+	switch len(key) {
+	case 16:
+		return cipherIDAES128GCM, nil
+	case 24:
+		return cipherIDAES192GCM, nil
+	case 32:
+		return cipherIDAES256GCM, nil
+	default:
+		return 0, fmt.Errorf("invalid AES key length %d, expected 16, 24 or 32 bytes", len(key))
+	}
+}
+
+func kdfName(id byte) string {
+	switch id {
+	case kdfIDNone:
+		return "key file"
+	case kdfIDArgon2id:
+		return "passphrase"
+	case kdfIDRecipients:
+		return "recipient identity"
+	default:
+		return fmt.Sprintf("unknown kdf %d", id)
+	}
+}
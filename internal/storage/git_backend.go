@@ -0,0 +1,529 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+const remoteName = "origin"
+
+// gitBackend keeps the vault in a git repo, every save is a commit pushed to the remote.
+type gitBackend struct {
+	url string
+}
+
+func newGitBackend(url string) *gitBackend {
+	return &gitBackend{url: url}
+}
+
+// Load returns the vault files at the given revision, the working copy is synced with the remote for the latest one.
+func (b *gitBackend) Load(rev string) (*Snapshot, error) {
+	if rev == "" {
+		wd, err := b.syncWorkdir()
+		if err != nil {
+			return nil, err
+		}
+		return wd.snapshot(wd.head)
+	}
+
+	wd, err := b.openWorkdir()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := wd.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %q: %v", rev, err)
+	}
+	return wd.snapshot(*hash)
+}
+
+// Save commits the files on top of base and pushes them, merging with remote commits if needed.
+// When the remote is unreachable the commit stays local until Sync.
+func (b *gitBackend) Save(base string, files map[string][]byte, message string, merge MergeFunc) (*Snapshot, error) {
+	var (
+		wd  *workdir
+		err error
+	)
+	if base != "" {
+		if wd, err = b.openWorkdir(); err != nil {
+			return nil, err
+		}
+		// Refuse to overwrite a commit made by another passy process after the data was read
+		if wd.head.String() != base {
+			return nil, ErrRemoteChanged
+		}
+		_ = wd.fetch()
+	} else if wd, err = b.syncWorkdir(); err != nil {
+		return nil, err
+	}
+	prevHead := wd.head
+
+	if err := wd.commitFiles(files, message); err != nil {
+		return nil, err
+	}
+	if wd.offline {
+		if merge == nil {
+			_ = wd.reset(prevHead)
+			return nil, ErrOffline
+		}
+		// the commit stays in the working copy until Sync
+		return &Snapshot{Files: files, Rev: wd.head.String(), Offline: true}, nil
+	}
+
+	if err := wd.pushMerged(merge); err != nil {
+		if merge != nil && errors.Is(err, errPush) {
+			// the remote rejected the push, the commit stays in the working copy until Sync
+			snapshot, serr := wd.snapshot(wd.head)
+			if serr != nil {
+				return nil, serr
+			}
+			snapshot.PushErr = err
+			return snapshot, nil
+		}
+		// drop local commits, so the working copy matches the remote again
+		_ = wd.reset(prevHead)
+		return nil, err
+	}
+	return wd.snapshot(wd.head)
+}
+
+// History returns commits which changed the file.
+func (b *gitBackend) History(file string) ([]Revision, error) {
+	wd, err := b.syncWorkdir()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := wd.repo.Log(&git.LogOptions{
+		From:       wd.head,
+		PathFilter: func(path string) bool { return path == file },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	defer iter.Close()
+
+	var revs []Revision
+	err = iter.ForEach(func(c *object.Commit) error {
+		revs = append(revs, Revision{
+			ID:      c.Hash.String(),
+			Time:    c.Author.When,
+			Author:  c.Author.Name,
+			Message: strings.TrimSpace(c.Message),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	return revs, nil
+}
+
+// Lock locks the working copy.
+func (b *gitBackend) Lock() (func() error, error) {
+	path, err := b.workdirPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	return lockFile(path + ".lock")
+}
+
+// Pending returns the number of local commits which are not pushed to the remote yet.
+func (b *gitBackend) Pending() (int, error) {
+	wd, err := b.openWorkdir()
+	if err != nil {
+		return 0, err
+	}
+	ahead, _, err := wd.divergence()
+	return ahead, err
+}
+
+// Sync fetches the remote and pushes local commits made while it was unreachable,
+// merging them with remote changes if needed. It returns the number of pushed commits.
+func (b *gitBackend) Sync(merge MergeFunc) (int, error) {
+	wd, err := b.openWorkdir()
+	if err != nil {
+		return 0, err
+	}
+	if err := wd.fetch(); err != nil {
+		return 0, err
+	}
+
+	ahead, behind, err := wd.divergence()
+	if err != nil {
+		return 0, err
+	}
+	if ahead == 0 {
+		if behind > 0 {
+			return 0, wd.reset(wd.remoteHead)
+		}
+		return 0, nil
+	}
+
+	prevHead := wd.head
+	if err := wd.pushMerged(merge); err != nil {
+		_ = wd.reset(prevHead)
+		return 0, err
+	}
+	return ahead, nil
+}
+
+// workdir is the local working copy of the vault repo, it's kept in the user cache dir
+// ($XDG_CACHE_HOME/passy or ~/.cache/passy) and fetched instead of being cloned on every call.
+type workdir struct {
+	path   string
+	repo   *git.Repository
+	branch plumbing.ReferenceName
+	head   plumbing.Hash
+	// remoteHead is the last known commit of the remote branch.
+	remoteHead plumbing.Hash
+	// offline is set when the remote can't be fetched.
+	offline bool
+}
+
+// workdirPath returns the working copy location for the configured repo.
+func (b *gitBackend) workdirPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find cache directory: %v", err)
+	}
+	sum := sha256.Sum256([]byte(b.url))
+	return filepath.Join(cacheDir, "passy", hex.EncodeToString(sum[:8])), nil
+}
+
+// openWorkdir opens the working copy, cloning the repo if there is none yet.
+func (b *gitBackend) openWorkdir() (*workdir, error) {
+	path, err := b.workdirPath()
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := git.PlainOpen(path)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		// remove leftovers of an interrupted clone
+		if err := os.RemoveAll(path); err != nil {
+			return nil, fmt.Errorf("failed to clean up %s: %v", path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %v", err)
+		}
+		repo, err = git.PlainClone(path, false, &git.CloneOptions{
+			URL: b.url,
+		})
+		if err != nil {
+			_ = os.RemoveAll(path)
+			return nil, fmt.Errorf("failed to clone repository: %v", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open local copy of the repository %s: %v", path, err)
+	}
+
+	ref, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository head: %v", err)
+	}
+	wd := &workdir{
+		path:   path,
+		repo:   repo,
+		branch: ref.Name(),
+		head:   ref.Hash(),
+	}
+	if wd.remoteHead, err = wd.remoteRef(); err != nil {
+		return nil, err
+	}
+	return wd, nil
+}
+
+// syncWorkdir opens the working copy and fast-forwards it to the remote.
+// If the remote is unreachable, the working copy is used as is and marked offline.
+// Local commits which are not pushed yet are kept, they are merged with the remote on the next push.
+func (b *gitBackend) syncWorkdir() (*workdir, error) {
+	wd, err := b.openWorkdir()
+	if err != nil {
+		return nil, err
+	}
+	if err := wd.fetch(); err != nil {
+		return wd, nil
+	}
+
+	ahead, behind, err := wd.divergence()
+	if err != nil {
+		return nil, err
+	}
+	if ahead == 0 && behind > 0 {
+		if err := wd.reset(wd.remoteHead); err != nil {
+			return nil, err
+		}
+	}
+	return wd, nil
+}
+
+// fetch fetches the remote and updates remoteHead, on failure the working copy is marked offline.
+func (wd *workdir) fetch() error {
+	err := wd.repo.Fetch(&git.FetchOptions{
+		RemoteName: remoteName,
+		Force:      true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		wd.offline = true
+		return fmt.Errorf("failed to fetch repository: %v", err)
+	}
+
+	wd.remoteHead, err = wd.remoteRef()
+	return err
+}
+
+// remoteRef returns the commit the remote branch pointed to on the last fetch.
+func (wd *workdir) remoteRef() (plumbing.Hash, error) {
+	ref, err := wd.repo.Reference(plumbing.NewRemoteReferenceName(remoteName, wd.branch.Short()), true)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get remote branch %s: %v", wd.branch.Short(), err)
+	}
+	return ref.Hash(), nil
+}
+
+// divergence returns the number of local commits missing on the remote and vice versa.
+func (wd *workdir) divergence() (ahead, behind int, err error) {
+	if wd.head == wd.remoteHead {
+		return 0, 0, nil
+	}
+
+	base, err := wd.mergeBase()
+	if err != nil {
+		return 0, 0, err
+	}
+	if ahead, err = wd.countCommits(wd.head, base); err != nil {
+		return 0, 0, err
+	}
+	if behind, err = wd.countCommits(wd.remoteHead, base); err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
+// mergeBase returns the common ancestor of the local and the remote heads.
+func (wd *workdir) mergeBase() (plumbing.Hash, error) {
+	local, err := wd.repo.CommitObject(wd.head)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to read local head: %v", err)
+	}
+	remote, err := wd.repo.CommitObject(wd.remoteHead)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to read remote head: %v", err)
+	}
+	bases, err := local.MergeBase(remote)
+	if err != nil || len(bases) == 0 {
+		return plumbing.ZeroHash, fmt.Errorf("local and remote histories are unrelated")
+	}
+	return bases[0].Hash, nil
+}
+
+// readFile returns the file content in the given commit, os.ErrNotExist if there is no such file.
+func (wd *workdir) readFile(hash plumbing.Hash, name string) ([]byte, error) {
+	c, err := wd.repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %v", hash, err)
+	}
+	f, err := c.File(name)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %v", name, hash, err)
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %v", name, hash, err)
+	}
+	return []byte(content), nil
+}
+
+// countCommits counts commits reachable from `from` but not from `base`, like git rev-list base..from.
+// A plain walk from `from` which stops at `base` would also count the history under a merge commit.
+func (wd *workdir) countCommits(from, base plumbing.Hash) (int, error) {
+	excluded := make(map[plumbing.Hash]bool)
+	if err := wd.walkCommits(base, nil, func(c *object.Commit) { excluded[c.Hash] = true }); err != nil {
+		return 0, err
+	}
+	count := 0
+	if err := wd.walkCommits(from, excluded, func(*object.Commit) { count++ }); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// walkCommits calls fn for every commit reachable from `from`, the excluded commits and their parents are skipped.
+func (wd *workdir) walkCommits(from plumbing.Hash, excluded map[plumbing.Hash]bool, fn func(*object.Commit)) error {
+	c, err := wd.repo.CommitObject(from)
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %v", from, err)
+	}
+	err = object.NewCommitPreorderIter(c, excluded, nil).ForEach(func(c *object.Commit) error {
+		fn(c)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read history: %v", err)
+	}
+	return nil
+}
+
+// reset moves the branch and the working tree to the given commit.
+func (wd *workdir) reset(hash plumbing.Hash) error {
+	w, err := wd.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %v", err)
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset}); err != nil {
+		return fmt.Errorf("failed to update local copy of the repository: %v", err)
+	}
+	wd.head = hash
+	return nil
+}
+
+// snapshot returns the vault files at the given commit.
+func (wd *workdir) snapshot(hash plumbing.Hash) (*Snapshot, error) {
+	files := make(map[string][]byte)
+	for _, name := range vaultFiles {
+		data, err := wd.readFile(hash, name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		files[name] = data
+	}
+	return &Snapshot{Files: files, Rev: hash.String(), Offline: wd.offline}, nil
+}
+
+// commitFiles writes the vault files to the working copy and commits them,
+// vault files missing in files are removed. With parents set, a merge commit is made.
+func (wd *workdir) commitFiles(files map[string][]byte, msg string, parents ...plumbing.Hash) error {
+	w, err := wd.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %v", err)
+	}
+
+	var names []string
+	for _, name := range vaultFiles {
+		path := filepath.Join(wd.path, name)
+		data, ok := files[name]
+		if !ok {
+			if _, err := os.Stat(path); err == nil {
+				if _, err := w.Remove(name); err != nil {
+					return fmt.Errorf("failed to remove %s: %v", name, err)
+				}
+			}
+			continue
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return fmt.Errorf("error writing %s: %v", name, err)
+		}
+		names = append(names, name)
+	}
+
+	head, err := wd.commit(msg, parents, names...)
+	if err != nil {
+		return err
+	}
+	wd.head = head
+	return nil
+}
+
+// pushMerged merges remote commits into the local ones if the histories diverged and pushes the result.
+func (wd *workdir) pushMerged(merge MergeFunc) error {
+	ahead, behind, err := wd.divergence()
+	if err != nil {
+		return err
+	}
+	if behind > 0 {
+		if err := wd.mergeRemote(merge); err != nil {
+			return err
+		}
+	} else if ahead == 0 {
+		return nil
+	}
+
+	if err := wd.push(); err != nil {
+		return err
+	}
+	wd.remoteHead = wd.head
+	return nil
+}
+
+// mergeRemote merges the remote vault files into the local ones and commits the result on top of both heads.
+func (wd *workdir) mergeRemote(merge MergeFunc) error {
+	if merge == nil {
+		return ErrRemoteChanged
+	}
+	base, err := wd.mergeBase()
+	if err != nil {
+		return err
+	}
+
+	var snapshots [3]*Snapshot
+	for i, hash := range []plumbing.Hash{base, wd.head, wd.remoteHead} {
+		if snapshots[i], err = wd.snapshot(hash); err != nil {
+			return err
+		}
+	}
+	merged, err := merge(snapshots[0].Files, snapshots[1].Files, snapshots[2].Files)
+	if err != nil {
+		return err
+	}
+	return wd.commitFiles(merged, "merge remote changes", wd.head, wd.remoteHead)
+}
+
+// commit commits files to the working copy with the specified commit message,
+// parents are set for merge commits only.
+func (wd *workdir) commit(commitMsg string, parents []plumbing.Hash, files ...string) (plumbing.Hash, error) {
+	// Stage the changes
+	w, err := wd.repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get worktree: %v", err)
+	}
+
+	// Add changes to the staging area
+	for _, file := range files {
+		if _, err = w.Add(file); err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to add changes to the repository: %v", err)
+		}
+	}
+
+	// Commit the changes
+	hash, err := w.Commit(commitMsg, &git.CommitOptions{
+		All:     true,
+		Parents: parents,
+	})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to commit changes to the repository: %v", err)
+	}
+	return hash, nil
+}
+
+// errPush is returned when the remote is fetched, but the push fails, e.g. the remote rejects it.
+var errPush = errors.New("failed to push changes to the repository")
+
+// push pushes local commits to the remote.
+func (wd *workdir) push() error {
+	err := wd.repo.Push(&git.PushOptions{
+		RemoteName: remoteName,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("%w: %v", errPush, err)
+	}
+	return nil
+}
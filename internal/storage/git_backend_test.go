@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// TestCountCommits checks a local merge commit, left by a failed push, doesn't make the history under it pending.
+func TestCountCommits(t *testing.T) {
+	repo, err := git.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(msg string, parents ...plumbing.Hash) plumbing.Hash {
+		t.Helper()
+		hash, err := w.Commit(msg, &git.CommitOptions{
+			AllowEmptyCommits: true,
+			Author:            &object.Signature{Name: "tester", Email: "t@e.st"},
+			Parents:           parents,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	// root - r0 - local - merge
+	//          \        /
+	//           remote -
+	root := commit("root")
+	r0 := commit("r0", root)
+	local := commit("local", r0)
+	remote := commit("remote", r0)
+	merge := commit("merge", local, remote)
+
+	wd := &workdir{repo: repo, head: merge, remoteHead: remote}
+	ahead, behind, err := wd.divergence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ahead != 2 || behind != 0 {
+		t.Errorf("%d ahead and %d behind, want 2 and 0", ahead, behind)
+	}
+
+	wd = &workdir{repo: repo, head: local, remoteHead: remote}
+	if ahead, behind, err = wd.divergence(); err != nil {
+		t.Fatal(err)
+	}
+	if ahead != 1 || behind != 1 {
+		t.Errorf("%d ahead and %d behind, want 1 and 1", ahead, behind)
+	}
+
+	if _, err := wd.countCommits(plumbing.NewHash("0123456789012345678901234567890123456789"), root); err == nil {
+		t.Error("a missing commit is counted")
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxPassHistory is the number of previous passwords kept for an entry.
+const maxPassHistory = 10
+
+// PassRecord is a previous password of an entry.
+type PassRecord struct {
+	Pass string
+	// Time is when the password was replaced.
+	Time time.Time
+}
+
+// setPass sets a new password, the current one goes to the history.
+func (f *Folder) setPass(pass string) {
+	if f.Pass == pass {
+		return
+	}
+
+	now := time.Now().UTC()
+	if f.Pass != "" {
+		f.History = append([]PassRecord{{Pass: f.Pass, Time: now}}, f.History...)
+		if len(f.History) > maxPassHistory {
+			f.History = f.History[:maxPassHistory]
+		}
+	}
+	f.Pass = pass
+	f.Changed = &now
+}
+
+// Rollback restores the n-th previous password of the key, 1 is the latest one.
+// The current password goes to the history, so the rollback can be undone.
+func (f *Folder) Rollback(key string, n int) error {
+	entry, found := f.GetSubFolder(key)
+	if !found {
+		return errors.Errorf("no such key: %s", key)
+	}
+	if n < 1 || n > len(entry.History) {
+		return errors.Errorf("%q has %d previous passwords, can't roll back %d", key, len(entry.History), n)
+	}
+
+	record := entry.History[n-1]
+	entry.History = append(entry.History[:n-1], entry.History[n:]...)
+	entry.setPass(record.Pass)
+	return nil
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Put sets the entry values to the key, missing folders are created. An existing entry is
+// replaced only if force is set, its password goes to the history.
+func (f *Folder) Put(key string, entry *Folder, force bool) error {
+	key = strings.Trim(key, folderSeparator)
+	if key == "" {
+		return errors.New("empty key")
+	}
+	if existing, found := f.GetSubFolder(key); found && existing.entry() != nil && !force {
+		return errors.Wrap(ErrKeyExists, key)
+	}
+
+	cf, err := f.Create(key)
+	if err != nil {
+		return err
+	}
+	values := *entry
+	values.Name, values.SubFolder = cf.Name, cf.SubFolder
+	values.Pass, values.History, values.Changed = cf.Pass, cf.History, cf.Changed
+	*cf = values
+	cf.setPass(entry.Pass)
+	return nil
+}
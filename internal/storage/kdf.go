@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+const kdfFileName = "kdf.json"
+
+// kdfVersion is the version of the default KDF parameters. Raise it together
+// with the defaults below, vaults with older parameters are re-keyed on the next Encrypt.
+const kdfVersion = 1
+
+const (
+	kdfArgon2id = "argon2id"
+
+	defaultArgonTime    = 3
+	defaultArgonMemory  = 64 * 1024 // KiB
+	defaultArgonThreads = 4
+	defaultSaltLen      = 16
+	aesKeyLen           = 32
+)
+
+// KDFParams describes how the vault key is derived from the master passphrase.
+// It is stored next to data.dat, so any machine with the passphrase can unlock the vault.
+type KDFParams struct {
+	Version   int
+	Algorithm string
+	Salt      []byte
+	Time      uint32
+	Memory    uint32 // KiB
+	Threads   uint8
+	KeyLen    uint32
+}
+
+// newKDFParams returns current default parameters with a fresh random salt.
+func newKDFParams() (*KDFParams, error) {
+	salt := make([]byte, defaultSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "failed to generate salt")
+	}
+	return &KDFParams{
+		Version:   kdfVersion,
+		Algorithm: kdfArgon2id,
+		Salt:      salt,
+		Time:      defaultArgonTime,
+		Memory:    defaultArgonMemory,
+		Threads:   defaultArgonThreads,
+		KeyLen:    aesKeyLen,
+	}, nil
+}
+
+// parseKDFParams decodes and validates KDF parameters read from the repo.
+func parseKDFParams(data []byte) (*KDFParams, error) {
+	var p KDFParams
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal kdf parameters")
+	}
+	if p.Version < 1 || p.Version > kdfVersion {
+		return nil, fmt.Errorf("unsupported kdf parameters version %d, please update passy", p.Version)
+	}
+	if p.Algorithm != kdfArgon2id {
+		return nil, fmt.Errorf("unsupported kdf algorithm %q", p.Algorithm)
+	}
+	if len(p.Salt) == 0 || p.Time == 0 || p.Memory == 0 || p.Threads == 0 {
+		return nil, errors.New("kdf parameters are incomplete")
+	}
+	if p.KeyLen != 16 && p.KeyLen != 24 && p.KeyLen != 32 {
+		return nil, fmt.Errorf("invalid kdf key length %d", p.KeyLen)
+	}
+	return &p, nil
+}
+
+func (p *KDFParams) marshal() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// outdated reports if the parameters are weaker than the current defaults.
+func (p *KDFParams) outdated() bool {
+	return p.Version < kdfVersion ||
+		p.Time < defaultArgonTime ||
+		p.Memory < defaultArgonMemory
+}
+
+// deriveKey derives the AES key from the passphrase.
+func (p *KDFParams) deriveKey(passphrase []byte) []byte {
+	return argon2.IDKey(passphrase, p.Salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrConflict is returned when the local and the remote vault changed the same entries
+// and there is no ConflictResolver to ask.
+var ErrConflict = errors.New("the local and the remote vault changed the same entries")
+
+// Conflict is an entry changed differently in the local and the remote vault.
+// Base, Ours and Theirs are nil when the entry doesn't exist in that version.
+type Conflict struct {
+	Key    string
+	Base   *Folder
+	Ours   *Folder
+	Theirs *Folder
+}
+
+// ConflictResolver picks the entry to keep, nil removes it.
+type ConflictResolver func(c Conflict) (*Folder, error)
+
+// MergeFolders makes a three-way merge of folder trees at the entry level: changes made
+// only on one side are applied, entries changed on both sides are resolved by the resolver.
+// Empty folders are merged the same way: one created or removed on one side is created or removed.
+func MergeFolders(base, ours, theirs *Folder, resolve ConflictResolver) (*Folder, error) {
+	baseEntries, oursEntries, theirsEntries := entries(base), entries(ours), entries(theirs)
+	baseEmpty, oursEmpty, theirsEmpty := emptyFolders(base), emptyFolders(ours), emptyFolders(theirs)
+
+	// keep our order of keys, new remote keys go after
+	oursKeys := mergeKeys(ours)
+	keys := append(make([]string, 0, len(oursKeys)), oursKeys...)
+	for _, key := range mergeKeys(theirs) {
+		if _, ok := oursEntries[key]; !ok && !oursEmpty[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	merged := &Folder{Name: "", SubFolder: []*Folder{}}
+	var conflicts []string
+	for _, key := range keys {
+		// an empty folder doesn't exist on one side only if that side removed it or put values in it
+		if o, t := oursEmpty[key], theirsEmpty[key]; o && t || o != t && !baseEmpty[key] {
+			if _, err := merged.Create(key); err != nil {
+				return nil, err
+			}
+		}
+
+		b, o, t := baseEntries[key], oursEntries[key], theirsEntries[key]
+
+		var entry *Folder
+		switch {
+		case sameEntry(o, t), sameEntry(b, t):
+			entry = o
+		case sameEntry(b, o):
+			entry = t
+		case resolve == nil:
+			conflicts = append(conflicts, key)
+			continue
+		default:
+			var err error
+			if entry, err = resolve(Conflict{Key: key, Base: b, Ours: o, Theirs: t}); err != nil {
+				return nil, errors.Wrapf(err, "failed to resolve conflict on %q", key)
+			}
+		}
+
+		if entry != nil {
+			merged.setEntry(key, entry)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, errors.Wrap(ErrConflict, strings.Join(conflicts, ", "))
+	}
+	return merged, nil
+}
+
+// entries returns all entries of the tree by their full key.
+func entries(f *Folder) map[string]*Folder {
+	res := make(map[string]*Folder)
+	walkEntries(f, "", func(key string, entry *Folder) {
+		res[key] = entry
+	})
+	return res
+}
+
+// emptyFolders returns the set of empty folder keys of the tree.
+func emptyFolders(f *Folder) map[string]bool {
+	res := make(map[string]bool)
+	if f == nil {
+		return res
+	}
+	for _, key := range f.EmptyFolders() {
+		res[key] = true
+	}
+	return res
+}
+
+// mergeKeys returns full keys of all entries and empty folders in the tree order.
+func mergeKeys(f *Folder) []string {
+	var keys []string
+	var walk func(f *Folder, prefix string)
+	walk = func(f *Folder, prefix string) {
+		for _, sf := range f.SubFolder {
+			key := sf.Name
+			if prefix != "" {
+				key = prefix + folderSeparator + sf.Name
+			}
+			if sf.entry() != nil || len(sf.SubFolder) == 0 {
+				keys = append(keys, key)
+			}
+			walk(sf, key)
+		}
+	}
+	if f != nil {
+		walk(f, "")
+	}
+	return keys
+}
+
+// entryKeys returns full keys of all entries in the tree order.
+func entryKeys(f *Folder) []string {
+	var keys []string
+	walkEntries(f, "", func(key string, _ *Folder) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// walkEntries calls fn for every folder holding a value, with the folder values only.
+func walkEntries(f *Folder, prefix string, fn func(key string, entry *Folder)) {
+	if f == nil {
+		return
+	}
+	for _, sf := range f.SubFolder {
+		key := sf.Name
+		if prefix != "" {
+			key = prefix + folderSeparator + sf.Name
+		}
+		if entry := sf.entry(); entry != nil {
+			fn(key, entry)
+		}
+		walkEntries(sf, key, fn)
+	}
+}
+
+// entry returns a copy of the folder values without subfolders, nil if there are no values.
+func (f *Folder) entry() *Folder {
+	entry := *f
+	entry.Name, entry.SubFolder = "", nil
+	if reflect.DeepEqual(entry, Folder{}) {
+		return nil
+	}
+	return &entry
+}
+
+// setEntry creates the key path and sets the entry values to it.
+func (f *Folder) setEntry(key string, entry *Folder) {
+	cf := f
+	for _, name := range strings.Split(key, folderSeparator) {
+		var next *Folder
+		for _, sf := range cf.SubFolder {
+			if sf.Name == name {
+				next = sf
+				break
+			}
+		}
+		if next == nil {
+			next = &Folder{Name: name, SubFolder: make([]*Folder, 0)}
+			cf.SubFolder = append(cf.SubFolder, next)
+		}
+		cf = next
+	}
+
+	values := *entry
+	values.Name, values.SubFolder = cf.Name, cf.SubFolder
+	*cf = values
+}
+
+func sameEntry(a, b *Folder) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
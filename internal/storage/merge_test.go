@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tree builds a folder tree of "key=pass" entries and "key/" empty folders.
+func tree(t *testing.T, items ...string) *Folder {
+	t.Helper()
+	root := &Folder{SubFolder: []*Folder{}}
+	for _, item := range items {
+		key, pass, _ := strings.Cut(strings.TrimSuffix(item, "/"), "=")
+		f, err := root.Create(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// set the password directly, Add stamps the change time
+		f.Pass = pass
+	}
+	return root
+}
+
+func TestMergeFolders(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs []string
+		keys, empty        []string
+	}{
+		{
+			name:   "entries changed on different sides",
+			base:   []string{"mail=1", "bank=2"},
+			ours:   []string{"mail=a", "bank=2"},
+			theirs: []string{"mail=1", "bank=2", "shop=3"},
+			keys:   []string{"mail", "bank", "shop"},
+		},
+		{
+			name:   "entry deleted on one side",
+			base:   []string{"mail=1", "bank=2"},
+			ours:   []string{"mail=1", "bank=2"},
+			theirs: []string{"mail=1"},
+			keys:   []string{"mail"},
+		},
+		{
+			name:   "empty folders kept and created",
+			base:   []string{"mail=1", "work/"},
+			ours:   []string{"mail=1", "work/", "home/"},
+			theirs: []string{"mail=1", "work/", "web/new/"},
+			keys:   []string{"mail"},
+			empty:  []string{"work", "home", "web/new"},
+		},
+		{
+			name:   "empty folders removed on one side",
+			base:   []string{"mail=1", "work/", "home/"},
+			ours:   []string{"mail=1", "home/"},
+			theirs: []string{"mail=1", "work/"},
+			keys:   []string{"mail"},
+		},
+		{
+			name:   "empty folder filled on one side",
+			base:   []string{"work/"},
+			ours:   []string{"work/"},
+			theirs: []string{"work/vpn=1"},
+			keys:   []string{"work/vpn"},
+		},
+		{
+			name:   "entry emptied on one side",
+			base:   []string{"mail=1"},
+			ours:   []string{"mail=1"},
+			theirs: []string{"mail/"},
+			empty:  []string{"mail"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := MergeFolders(tree(t, tt.base...), tree(t, tt.ours...), tree(t, tt.theirs...), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keys := merged.Keys(); !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("keys %v, want %v", keys, tt.keys)
+			}
+			if empty := merged.EmptyFolders(); !reflect.DeepEqual(empty, tt.empty) {
+				t.Errorf("empty folders %v, want %v", empty, tt.empty)
+			}
+		})
+	}
+}
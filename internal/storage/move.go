@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrKeyExists is returned when the destination key of a move or a copy exists and the operation is not forced.
+var ErrKeyExists = errors.New("the key already exists")
+
+// Move moves the entry or the whole folder to the new key.
+// An existing destination is replaced only if force is set.
+func (f *Folder) Move(src, dst string, force bool) error {
+	src, dst = strings.Trim(src, folderSeparator), strings.Trim(dst, folderSeparator)
+	node, err := f.prepareTransfer(src, dst, force)
+	if err != nil {
+		return err
+	}
+
+	parent, _ := f.lookup(src)
+	parent.removeChild(node)
+	f.prune(parentKey(src))
+	return f.attach(dst, node)
+}
+
+// Copy copies the entry or the whole folder to the new key.
+// An existing destination is replaced only if force is set.
+func (f *Folder) Copy(src, dst string, force bool) error {
+	src, dst = strings.Trim(src, folderSeparator), strings.Trim(dst, folderSeparator)
+	node, err := f.prepareTransfer(src, dst, force)
+	if err != nil {
+		return err
+	}
+
+	clone, err := node.clone()
+	if err != nil {
+		return err
+	}
+	return f.attach(dst, clone)
+}
+
+// Rename changes the last key segment of the entry or the folder.
+func (f *Folder) Rename(src, newName string, force bool) error {
+	if newName == "" || strings.Contains(newName, folderSeparator) {
+		return errors.Errorf("invalid name %q, use mv to move keys between folders", newName)
+	}
+
+	src = strings.Trim(src, folderSeparator)
+	dst := newName
+	if parent := parentKey(src); parent != "" {
+		dst = parent + folderSeparator + newName
+	}
+	return f.Move(src, dst, force)
+}
+
+// prepareTransfer checks the source and the destination keys and removes the destination if it's forced.
+func (f *Folder) prepareTransfer(src, dst string, force bool) (*Folder, error) {
+	if f.Name != "" {
+		return nil, errors.New("should move from the head only")
+	}
+	if src == "" || dst == "" {
+		return nil, errors.New("empty key")
+	}
+	if src == dst {
+		return nil, errors.New("the source and the destination are the same")
+	}
+	if isSubKey(dst, src) {
+		return nil, errors.Errorf("can't move %q inside of itself", src)
+	}
+	if isSubKey(src, dst) {
+		return nil, errors.Errorf("can't replace %q with its own subkey", dst)
+	}
+
+	_, node := f.lookup(src)
+	if node == nil {
+		return nil, errors.Errorf("no such key: %s", src)
+	}
+
+	if parent, existing := f.lookup(dst); existing != nil {
+		if !force {
+			return nil, errors.Wrap(ErrKeyExists, dst)
+		}
+		parent.removeChild(existing)
+	}
+	return node, nil
+}
+
+// attach puts the node on the key, missing parent folders are created.
+func (f *Folder) attach(key string, node *Folder) error {
+	parent := f
+	if pk := parentKey(key); pk != "" {
+		var err error
+		if parent, err = f.Create(pk); err != nil {
+			return err
+		}
+	}
+
+	node.Name = key[strings.LastIndex(key, folderSeparator)+1:]
+	parent.SubFolder = append(parent.SubFolder, node)
+	return nil
+}
+
+// lookup returns the folder on the key and its parent, the folder is nil if there is no such key.
+func (f *Folder) lookup(key string) (parent, node *Folder) {
+	parent = f
+	if pk := parentKey(key); pk != "" {
+		var found bool
+		if parent, found = f.GetSubFolder(pk); !found {
+			return nil, nil
+		}
+	}
+
+	name := key[strings.LastIndex(key, folderSeparator)+1:]
+	for _, sf := range parent.SubFolder {
+		if sf.Name == name {
+			return parent, sf
+		}
+	}
+	return parent, nil
+}
+
+func (f *Folder) removeChild(node *Folder) {
+	for i, sf := range f.SubFolder {
+		if sf == node {
+			f.SubFolder = append(f.SubFolder[:i], f.SubFolder[i+1:]...)
+			return
+		}
+	}
+}
+
+// prune removes folders left without entries on the key path, starting from the deepest one.
+func (f *Folder) prune(key string) {
+	for ; key != ""; key = parentKey(key) {
+		parent, node := f.lookup(key)
+		if node == nil || len(node.SubFolder) > 0 || node.entry() != nil {
+			return
+		}
+		parent.removeChild(node)
+	}
+}
+
+func (f *Folder) clone() (*Folder, error) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to copy the folder")
+	}
+	var clone Folder
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, errors.Wrap(err, "failed to copy the folder")
+	}
+	return &clone, nil
+}
+
+// parentKey returns the key without the last segment.
+func parentKey(key string) string {
+	i := strings.LastIndex(key, folderSeparator)
+	if i < 0 {
+		return ""
+	}
+	return key[:i]
+}
+
+// isSubKey reports if the key is inside of the parent folder.
+func isSubKey(key, parent string) bool {
+	return strings.HasPrefix(key, parent+folderSeparator)
+}
@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Recipients mode: the vault is encrypted with a random content key, which is wrapped
+// for each recipient's X25519 public key (age-style stanzas) and stored in the header extension.
+
+const (
+	publicKeyPrefix = "x25519:"
+	wrapInfo        = "passy x25519 key wrap"
+
+	defaultRecipientName = "owner"
+)
+
+// Recipient is a team member the vault content key is wrapped for.
+type Recipient struct {
+	Name      string
+	PublicKey []byte
+}
+
+// String returns the recipient public key in the text form.
+func (r Recipient) String() string {
+	return FormatPublicKey(r.PublicKey)
+}
+
+// stanza is the content key wrapped for a single recipient.
+type stanza struct {
+	Name       string
+	Recipient  []byte
+	Ephemeral  []byte
+	WrappedKey []byte
+}
+
+// GenerateIdentity generates a new X25519 private key.
+func GenerateIdentity() ([]byte, error) {
+	identity := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(identity); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// PublicKey returns the public key for the identity.
+func PublicKey(identity []byte) ([]byte, error) {
+	return curve25519.X25519(identity, curve25519.Basepoint)
+}
+
+// FormatPublicKey returns the text form of a public key.
+func FormatPublicKey(pub []byte) string {
+	return publicKeyPrefix + base64.RawURLEncoding.EncodeToString(pub)
+}
+
+// ParsePublicKey parses the text form of a public key.
+func ParsePublicKey(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, publicKeyPrefix) {
+		return nil, fmt.Errorf("public key should start with %q", publicKeyPrefix)
+	}
+	pub, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, publicKeyPrefix))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode public key")
+	}
+	if len(pub) != curve25519.PointSize {
+		return nil, fmt.Errorf("invalid public key length %d", len(pub))
+	}
+	return pub, nil
+}
+
+func readIdentity(path string) ([]byte, error) {
+	identity, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(identity) != curve25519.ScalarSize {
+		return nil, fmt.Errorf("invalid identity length %d", len(identity))
+	}
+	return identity, nil
+}
+
+// UsesRecipients reports if the vault key is wrapped for recipients.
+func (s *Storage) UsesRecipients() bool {
+	return s.identity != nil
+}
+
+// SetIdentity switches the storage to the recipients mode.
+// If there are no recipients yet, the identity owner becomes the first one.
+func (s *Storage) SetIdentity(identity []byte, name string) error {
+	pub, err := PublicKey(identity)
+	if err != nil {
+		return errors.Wrap(err, "invalid identity")
+	}
+
+	s.identity = identity
+	s.passphrase = nil
+	s.KDF = nil
+	s.rekeyed = true
+	if len(s.Recipients) == 0 {
+		s.Recipients = []Recipient{{Name: name, PublicKey: pub}}
+	}
+	return s.newContentKey()
+}
+
+// AddRecipient wraps the content key for one more public key on the next Encrypt.
+func (s *Storage) AddRecipient(name string, pub []byte) error {
+	if !s.UsesRecipients() {
+		return errors.New("the vault is not shared with recipients")
+	}
+	for _, r := range s.Recipients {
+		if r.Name == name {
+			return fmt.Errorf("recipient %q already exists", name)
+		}
+		if string(r.PublicKey) == string(pub) {
+			return fmt.Errorf("the key is already added as %q", r.Name)
+		}
+	}
+	s.Recipients = append(s.Recipients, Recipient{Name: name, PublicKey: pub})
+	return nil
+}
+
+// RemoveRecipient removes the recipient and generates a new content key,
+// so the removed key can't decrypt new versions of the vault.
+func (s *Storage) RemoveRecipient(name string) error {
+	if !s.UsesRecipients() {
+		return errors.New("the vault is not shared with recipients")
+	}
+
+	own, err := PublicKey(s.identity)
+	if err != nil {
+		return err
+	}
+	for i, r := range s.Recipients {
+		if r.Name != name {
+			continue
+		}
+		if string(r.PublicKey) == string(own) {
+			return errors.New("can't remove your own key, you would lose access to the vault")
+		}
+		s.Recipients = append(s.Recipients[:i], s.Recipients[i+1:]...)
+		s.rekeyed = true
+		return s.newContentKey()
+	}
+	return fmt.Errorf("recipient %q not found", name)
+}
+
+// ensureContentKey creates the content key for a new shared vault, the identity owner is its first recipient.
+func (s *Storage) ensureContentKey() error {
+	if !s.UsesRecipients() || s.PrivKey != nil {
+		return nil
+	}
+	if len(s.Recipients) == 0 {
+		pub, err := PublicKey(s.identity)
+		if err != nil {
+			return err
+		}
+		s.Recipients = []Recipient{{Name: defaultRecipientName, PublicKey: pub}}
+	}
+	return s.newContentKey()
+}
+
+func (s *Storage) newContentKey() error {
+	key, err := GenerateAESKey(aesKeyLen)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate content key")
+	}
+	s.PrivKey = key
+	return nil
+}
+
+// wrapKey returns the header extension with the content key wrapped for every recipient.
+func (s *Storage) wrapKey() ([]byte, error) {
+	if len(s.Recipients) == 0 {
+		return nil, errors.New("the vault has no recipients")
+	}
+
+	stanzas := make([]stanza, 0, len(s.Recipients))
+	for _, r := range s.Recipients {
+		ephemeral, err := GenerateIdentity()
+		if err != nil {
+			return nil, err
+		}
+		ephemeralPub, err := PublicKey(ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := curve25519.X25519(ephemeral, r.PublicKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid public key of %q", r.Name)
+		}
+
+		aead, err := wrapAEAD(shared, ephemeralPub, r.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		// the wrapping key is unique for each ephemeral key, so the zero nonce is fine
+		nonce := make([]byte, aead.NonceSize())
+		stanzas = append(stanzas, stanza{
+			Name:       r.Name,
+			Recipient:  r.PublicKey,
+			Ephemeral:  ephemeralPub,
+			WrappedKey: aead.Seal(nil, nonce, s.PrivKey, nil),
+		})
+	}
+	return json.Marshal(stanzas)
+}
+
+// unwrapKey finds the stanza for the identity, unwraps the content key and loads the recipients.
+func (s *Storage) unwrapKey(extension []byte) error {
+	var stanzas []stanza
+	if err := json.Unmarshal(extension, &stanzas); err != nil {
+		return errors.Wrap(ErrCorrupted, "failed to read recipients")
+	}
+
+	own, err := PublicKey(s.identity)
+	if err != nil {
+		return err
+	}
+
+	var key []byte
+	recipients := make([]Recipient, 0, len(stanzas))
+	for _, st := range stanzas {
+		recipients = append(recipients, Recipient{Name: st.Name, PublicKey: st.Recipient})
+		if key != nil || string(st.Recipient) != string(own) {
+			continue
+		}
+
+		shared, err := curve25519.X25519(s.identity, st.Ephemeral)
+		if err != nil {
+			return errors.Wrap(ErrCorrupted, "invalid ephemeral key")
+		}
+		aead, err := wrapAEAD(shared, st.Ephemeral, st.Recipient)
+		if err != nil {
+			return err
+		}
+		if key, err = aead.Open(nil, make([]byte, aead.NonceSize()), st.WrappedKey, nil); err != nil {
+			return errors.Wrap(ErrCorrupted, "failed to unwrap the content key")
+		}
+	}
+	if key == nil {
+		return errors.Wrap(ErrWrongKey, "the vault is not shared with your identity")
+	}
+
+	s.PrivKey = key
+	s.Recipients = recipients
+	return nil
+}
+
+func wrapAEAD(shared, ephemeralPub, recipientPub []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeralPub...), recipientPub...)
+	wrapKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(wrapInfo)), wrapKey); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(wrapKey)
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Log returns vault revisions which changed data.dat, the newest first.
+func (s *Storage) Log() ([]Revision, error) {
+	unlock, err := s.backend.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return s.backend.History(dataFileName)
+}
+
+// DecryptAt decrypts the vault at the given revision.
+// Revisions encrypted with another key (before a key rotation) can't be decrypted.
+func (s *Storage) DecryptAt(rev string) (*Folder, error) {
+	unlock, err := s.backend.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	snapshot, err := s.backend.Load(rev)
+	if err != nil {
+		return nil, err
+	}
+	folder, _, err := s.decryptFiles(snapshot.Files)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt revision %s", rev)
+	}
+	return folder, nil
+}
+
+// PassChangeTimes finds when the current passwords of the keys were set using the vault revisions,
+// it's needed for entries saved before Folder.Changed was kept. The search stops at the first revision
+// which can't be decrypted (e.g. before a key rotation), keys unchanged since then get its time.
+func (s *Storage) PassChangeTimes(current *Folder, keys []string) (map[string]time.Time, error) {
+	pending := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if entry, found := current.GetSubFolder(key); found {
+			pending[key] = entry.Pass
+		}
+	}
+
+	revs, err := s.Log()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]time.Time, len(keys))
+	for _, rev := range revs {
+		if len(pending) == 0 {
+			break
+		}
+		folder, err := s.DecryptAt(rev.ID)
+		if err != nil {
+			break
+		}
+		for key, pass := range pending {
+			if entry, found := folder.GetSubFolder(key); found && entry.Pass == pass {
+				res[key] = rev.Time
+			} else {
+				delete(pending, key)
+			}
+		}
+	}
+	return res, nil
+}
+
+// Change is an entry added, changed or removed between two versions of the vault.
+// Old is nil for added entries, New is nil for removed ones.
+type Change struct {
+	Key string
+	Old *Folder
+	New *Folder
+}
+
+// DiffFolders returns entries which differ in the trees, in the order of keys in the new tree,
+// removed entries go last.
+func DiffFolders(old, new *Folder) []Change {
+	oldEntries, newEntries := entries(old), entries(new)
+
+	var changes []Change
+	for _, key := range entryKeys(new) {
+		if o, n := oldEntries[key], newEntries[key]; !sameEntry(o, n) {
+			changes = append(changes, Change{Key: key, Old: o, New: n})
+		}
+	}
+	for _, key := range entryKeys(old) {
+		if _, ok := newEntries[key]; !ok {
+			changes = append(changes, Change{Key: key, Old: oldEntries[key]})
+		}
+	}
+	return changes
+}
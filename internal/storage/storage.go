@@ -4,12 +4,20 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
-	"io/fs"
 	"net/http"
 	"os"
-	"path/filepath"
+	"reflect"
 
-	git "github.com/go-git/go-git/v5"
+	"github.com/pkg/errors"
+)
+
+const dataFileName = "data.dat"
+
+var (
+	// ErrRemoteChanged is returned by Store when the vault was changed in a way that can't be merged after Update.
+	ErrRemoteChanged = errors.New("the vault has changed since it was read, please retry")
+	// ErrOffline is returned by Store when the change must reach the remote, but it's unreachable.
+	ErrOffline = errors.New("the remote is unreachable")
 )
 
 // Storage struct to hold the data read from the file
@@ -17,157 +25,335 @@ type Storage struct {
 	PrivKey []byte
 	Data    string
 	Cfg     *Config
-	updated bool
+	// KDF holds key derivation parameters when the key is derived from a passphrase.
+	KDF *KDFParams
+	// Recipients the content key is wrapped for when the vault is shared with identities.
+	Recipients []Recipient
+	identity   []byte
+	passphrase []byte
+	updated    bool
+	// rekeyed is set when the key material was changed after the data was read,
+	// other revisions encrypted with the previous key can't be merged with the data then.
+	rekeyed bool
+	// Offline is set when the remote is unreachable, the local copy of the repo is used then
+	// and new commits are pushed later by Sync.
+	Offline bool
+	// PushErr is why the last stored change wasn't pushed, the change is committed locally and
+	// pushed later by Sync.
+	PushErr error
+	backend Backend
+	// rev is the backend revision the data was read from.
+	rev string
+	// Resolve is asked about entries changed both locally and remotely, when it's nil Store fails with ErrConflict.
+	Resolve ConflictResolver
 }
 
 // New initializes a new Storage instance
 func New(cfg *Config) (*Storage, error) {
+	if cfg.UsePassphrase {
+		return nil, errors.New("the vault is configured to be unlocked with a passphrase")
+	}
+
+	if cfg.IdentityPath != "" {
+		identity, err := readIdentity(cfg.IdentityPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading identity: %v", err)
+		}
+		backend, err := newBackend(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &Storage{identity: identity, Cfg: cfg, backend: backend}, nil
+	}
+
 	// Read the private key
 	privKey, err := readKey(cfg.PrivKeyPath)
 	if err != nil {
 		return nil, fmt.Errorf("error reading private key: %v", err)
 	}
 
+	backend, err := newBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	storage := &Storage{
 		PrivKey: privKey,
 		Cfg:     cfg,
+		backend: backend,
 	}
 	return storage, nil
 }
 
-// Update updates data inside of a storage from the git repo.
+// NewWithPassphrase initializes a new Storage instance which derives its key from the passphrase.
+// The key is derived on Update, when KDF parameters are read from the repo.
+func NewWithPassphrase(cfg *Config, passphrase []byte) (*Storage, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	backend, err := newBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Storage{
+		Cfg:        cfg,
+		passphrase: passphrase,
+		backend:    backend,
+	}, nil
+}
+
+// SetPassphrase switches the storage to the passphrase derived key.
+// New KDF parameters are generated on the next Encrypt.
+func (s *Storage) SetPassphrase(passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.New("empty passphrase")
+	}
+	s.passphrase = passphrase
+	s.KDF = nil
+	s.identity = nil
+	s.Recipients = nil
+	s.rekeyed = true
+	return nil
+}
+
+// Rekey switches the storage to a new raw key, the data is re-encrypted with it on the next Encrypt.
+func (s *Storage) Rekey(key []byte) error {
+	if _, err := cipherID(key); err != nil {
+		return err
+	}
+	s.PrivKey = key
+	s.passphrase = nil
+	s.KDF = nil
+	s.identity = nil
+	s.Recipients = nil
+	s.rekeyed = true
+	return nil
+}
+
+// Update updates data inside of a storage from the backend.
 func (s *Storage) Update() error {
 	if s.updated {
 		return nil
 	}
 	s.updated = true
 
-	// Clone the Git repository to a temporary directory
-	tempDir, err := os.MkdirTemp("", "repo")
+	unlock, err := s.backend.Lock()
 	if err != nil {
-		return fmt.Errorf("error creating temporary directory: %v", err)
+		return err
 	}
+	defer unlock()
 
-	// Clone the repository
-	if err := cloneRepo(s.Cfg.GitRepoPath, tempDir); err != nil {
+	snapshot, err := s.backend.Load("")
+	if err != nil {
 		return err
 	}
+	s.rev, s.Offline = snapshot.Rev, snapshot.Offline
+	return s.load(snapshot.Files)
+}
 
-	// Read the data.dat file
-	dataFilePath := filepath.Join(tempDir, "data.dat")
-	data, err := os.ReadFile(dataFilePath)
-	if err != nil {
-		if _, ok := err.(*os.PathError); ok {
-			s.Data = ""
-			return nil
+// load reads KDF parameters and the data from the vault files.
+func (s *Storage) load(files map[string][]byte) error {
+	if s.passphrase != nil {
+		if kdf, ok := files[kdfFileName]; ok {
+			if err := s.loadKDF(kdf); err != nil {
+				return err
+			}
+		} else {
+			s.KDF = nil
 		}
-		return fmt.Errorf("error reading data.dat: %v", err)
+	}
+
+	data, ok := files[dataFileName]
+	if !ok {
+		s.Data = ""
+		return nil
+	}
+	if s.passphrase != nil && s.KDF == nil {
+		return errors.New("the vault is not initialized with a passphrase, run passy --init-passphrase")
 	}
 	s.Data = base64.StdEncoding.EncodeToString(data)
 	return nil
 }
 
-// Store stores s.Data in the git repo.
-func (s *Storage) Store(message *string) error {
-	// Clone the Git repository to a temporary directory
-	tempDir, err := os.MkdirTemp("", "repo")
+// loadKDF parses KDF parameters and derives the key, unless the parameters are the ones already in use.
+func (s *Storage) loadKDF(data []byte) error {
+	params, err := parseKDFParams(data)
 	if err != nil {
-		return fmt.Errorf("error creating temporary directory: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Clone the repository
-	if err := cloneRepo(s.Cfg.GitRepoPath, tempDir); err != nil {
 		return err
 	}
+	if s.KDF != nil && s.PrivKey != nil && reflect.DeepEqual(params, s.KDF) {
+		return nil
+	}
+	s.KDF = params
+	s.PrivKey = params.deriveKey(s.passphrase)
+	return nil
+}
 
-	// Write the data.dat file
-	dataFilePath := filepath.Join(tempDir, "data.dat")
-	if err := os.WriteFile(dataFilePath, []byte(s.Data), fs.ModePerm); err != nil {
-		return fmt.Errorf("error reading data.dat: %v", err)
+// Store stores s.Data in the backend.
+// If the vault got new revisions since the data was read, the local and the new vaults
+// are merged, s.Resolve is asked about entries changed on both sides.
+// A vault with a new key is never merged or left pending, Store fails with ErrRemoteChanged
+// or ErrOffline instead.
+// If the remote rejects the push, the change is kept locally for Sync and s.PushErr is set.
+func (s *Storage) Store(message *string) error {
+	files, err := s.files()
+	if err != nil {
+		return err
 	}
 
 	msg := defaultCommitMessage
 	if message != nil {
 		msg = *message
 	}
-	return commitRepo(tempDir, msg)
-}
 
-// readKey reads a key from a file or downloads it if it's a URL
-func readKey(path string) ([]byte, error) {
-	if isURL(path) {
-		return downloadFile(path)
+	unlock, err := s.backend.Lock()
+	if err != nil {
+		return err
 	}
-	return os.ReadFile(path)
+	defer unlock()
+
+	base := ""
+	if s.updated {
+		base = s.rev
+	}
+	merge := s.merge
+	if s.rekeyed {
+		merge = nil
+	}
+	snapshot, err := s.backend.Save(base, files, msg, merge)
+	if err != nil {
+		return err
+	}
+	s.rev, s.Offline, s.PushErr = snapshot.Rev, snapshot.Offline, snapshot.PushErr
+	s.rekeyed = false
+	s.Data = string(snapshot.Files[dataFileName])
+	return nil
 }
 
-// isURL checks if the given string is a valid URL
-func isURL(path string) bool {
-	return len(path) > 5 && path[:5] == "https"
+// files returns the vault files to store.
+func (s *Storage) files() (map[string][]byte, error) {
+	files := map[string][]byte{dataFileName: []byte(s.Data)}
+	if s.KDF != nil {
+		kdf, err := s.KDF.marshal()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal kdf parameters")
+		}
+		files[kdfFileName] = kdf
+	}
+	return files, nil
 }
 
-// downloadFile downloads a file from the given URL
-func downloadFile(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+// merge decrypts three versions of the vault, merges them and returns the encrypted result.
+func (s *Storage) merge(base, ours, theirs map[string][]byte) (map[string][]byte, error) {
+	baseFolder, baseRev, err := s.decryptFiles(base)
+	if errors.Is(err, ErrWrongKey) {
+		// the key was changed by a local revision, e.g. one made before Store refused to leave it pending
+		return nil, errors.Wrap(ErrRemoteChanged, "the vault key was changed locally")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to download file: %v", err)
+		return nil, errors.Wrap(err, "failed to decrypt the common version of the vault")
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download file: status code %d", resp.StatusCode)
+	oursFolder, oursRev, err := s.decryptFiles(ours)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt the local version of the vault")
+	}
+	theirsFolder, theirsRev, err := s.decryptFiles(theirs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt the remote version of the vault")
+	}
+	if baseRev.Data != "" && !sameKey(baseRev, theirsRev) {
+		return nil, errors.Wrap(ErrRemoteChanged, "the vault key was changed remotely")
 	}
 
-	return io.ReadAll(resp.Body)
+	merged, err := MergeFolders(baseFolder, oursFolder, theirsFolder, s.Resolve)
+	if err != nil {
+		return nil, err
+	}
+	// the merged vault keeps the key of the local version: s may have no key material loaded,
+	// e.g. on Sync, and Encrypt would generate a new key or KDF salt then
+	if err := oursRev.seal(merged); err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt the merged vault")
+	}
+	return oursRev.files()
 }
 
-// cloneRepo clones the specified Git repository into the given directory
-func cloneRepo(repoPath, destDir string) error {
-	_, err := git.PlainClone(destDir, false, &git.CloneOptions{
-		URL: repoPath,
-	})
+// decryptFiles decrypts another version of the vault files.
+// The returned storage holds the key material of that version.
+func (s *Storage) decryptFiles(files map[string][]byte) (*Folder, *Storage, error) {
+	rev := &Storage{
+		PrivKey:    s.PrivKey,
+		Cfg:        s.Cfg,
+		KDF:        s.KDF,
+		identity:   s.identity,
+		passphrase: s.passphrase,
+		updated:    true,
+	}
+	if err := rev.load(files); err != nil {
+		return nil, nil, err
+	}
+
+	folder, err := rev.Decrypt()
 	if err != nil {
-		return fmt.Errorf("failed to clone repository: %v", err)
+		return nil, nil, err
 	}
-	return nil
+	return folder, rev, nil
 }
 
-// commitRepo commits changes to the repository with the specified commit message
-func commitRepo(repoPath, commitMsg string) error {
-	// Open the existing repository
-	repo, err := git.PlainOpen(repoPath)
-	if err != nil {
-		return fmt.Errorf("failed to open repository: %v", err)
+// sameKey reports if both versions of the vault are encrypted with the same key for the same recipients.
+func sameKey(a, b *Storage) bool {
+	return string(a.PrivKey) == string(b.PrivKey) && reflect.DeepEqual(a.Recipients, b.Recipients)
+}
+
+// Pending returns the number of local revisions which are not pushed to the remote yet.
+func (s *Storage) Pending() (int, error) {
+	syncer, ok := s.backend.(Syncer)
+	if !ok {
+		return 0, nil
 	}
+	return syncer.Pending()
+}
 
-	// Stage the changes
-	w, err := repo.Worktree()
-	if err != nil {
-		return fmt.Errorf("failed to get worktree: %v", err)
+// Sync pushes local revisions made while the remote was unreachable,
+// merging them with remote changes if needed. It returns the number of pushed revisions.
+func (s *Storage) Sync() (int, error) {
+	syncer, ok := s.backend.(Syncer)
+	if !ok {
+		return 0, nil
 	}
 
-	// Add changes to the staging area
-	_, err = w.Add("data.dat")
+	unlock, err := s.backend.Lock()
 	if err != nil {
-		return fmt.Errorf("failed to add changes to the repository: %v", err)
+		return 0, err
 	}
+	defer unlock()
 
-	// Commit the changes
-	_, err = w.Commit(commitMsg, &git.CommitOptions{
-		All: true,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to commit changes to the repository: %v", err)
+	return syncer.Sync(s.merge)
+}
+
+// readKey reads a key from a file or downloads it if it's a URL
+func readKey(path string) ([]byte, error) {
+	if isURL(path) {
+		return downloadFile(path)
 	}
+	return os.ReadFile(path)
+}
 
-	// Push the changes
-	err = repo.Push(&git.PushOptions{
-		RemoteName: "origin",
-	})
+// isURL checks if the given string is a valid URL
+func isURL(path string) bool {
+	return len(path) > 5 && path[:5] == "https"
+}
+
+// downloadFile downloads a file from the given URL
+func downloadFile(url string) ([]byte, error) {
+	resp, err := http.Get(url)
 	if err != nil {
-		return fmt.Errorf("failed to push changes to the repository: %v", err)
+		return nil, fmt.Errorf("failed to download file: %v", err)
 	}
+	defer resp.Body.Close()
 
-	return nil
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file: status code %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
 }
@@ -0,0 +1,419 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testConfig returns the config of a vault in a temporary directory with a new key file.
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	key, err := GenerateAESKey(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "key.aes")
+	if err := os.WriteFile(keyPath, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &Config{PrivKeyPath: keyPath, Backend: BackendDir, DirPath: filepath.Join(dir, "vault")}
+}
+
+func openTestStorage(t *testing.T, cfg *Config) (*Storage, *Folder) {
+	t.Helper()
+	st, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if st.Data == "" {
+		return st, &Folder{}
+	}
+	root, err := st.Decrypt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st, root
+}
+
+// store adds the passwords to the tree and stores it.
+func store(t *testing.T, st *Storage, root *Folder, passwords map[string]string) error {
+	t.Helper()
+	for key, pass := range passwords {
+		if err := root.Add(key, pass); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Encrypt(root); err != nil {
+		t.Fatal(err)
+	}
+	return st.Store(nil)
+}
+
+// checkPasswords reads the vault with a new Storage and compares the passwords.
+func checkPasswords(t *testing.T, cfg *Config, want map[string]string) {
+	t.Helper()
+	_, root := openTestStorage(t, cfg)
+	keys := root.Keys()
+	if len(keys) != len(want) {
+		t.Errorf("the vault has keys %v, want %d keys", keys, len(want))
+	}
+	for key, pass := range want {
+		entry, ok := root.GetSubFolder(key)
+		if !ok {
+			t.Errorf("%s is missing", key)
+			continue
+		}
+		if entry.Pass != pass {
+			t.Errorf("%s: got %q, want %q", key, entry.Pass, pass)
+		}
+	}
+}
+
+func TestStoreDecrypt(t *testing.T) {
+	cfg := testConfig(t)
+	st, root := openTestStorage(t, cfg)
+	if err := store(t, st, root, map[string]string{"mail": "1", "web/github": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	checkPasswords(t, cfg, map[string]string{"mail": "1", "web/github": "2"})
+
+	st, root = openTestStorage(t, cfg)
+	if err := store(t, st, root, map[string]string{"mail": "3"}); err != nil {
+		t.Fatal(err)
+	}
+	checkPasswords(t, cfg, map[string]string{"mail": "3", "web/github": "2"})
+
+	revs, err := st.Log()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 {
+		t.Fatalf("%d revisions, want 2", len(revs))
+	}
+	old, err := st.DecryptAt(revs[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := old.GetSubFolder("mail"); entry == nil || entry.Pass != "1" {
+		t.Errorf("mail at the first revision: %+v", entry)
+	}
+}
+
+func TestStoreWrongKey(t *testing.T) {
+	cfg := testConfig(t)
+	st, root := openTestStorage(t, cfg)
+	if err := store(t, st, root, map[string]string{"mail": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	other := testConfig(t)
+	other.DirPath = cfg.DirPath
+	st, err := New(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Decrypt(); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("got %v, want %v", err, ErrWrongKey)
+	}
+}
+
+func TestStoreMerge(t *testing.T) {
+	cfg := testConfig(t)
+	st, root := openTestStorage(t, cfg)
+	if err := store(t, st, root, map[string]string{"mail": "1", "bank": "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// both read the same revision and change different entries
+	a, rootA := openTestStorage(t, cfg)
+	b, rootB := openTestStorage(t, cfg)
+	if err := store(t, a, rootA, map[string]string{"mail": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store(t, b, rootB, map[string]string{"shop": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	checkPasswords(t, cfg, map[string]string{"mail": "a", "bank": "2", "shop": "b"})
+}
+
+func TestStoreConflict(t *testing.T) {
+	cfg := testConfig(t)
+	st, root := openTestStorage(t, cfg)
+	if err := store(t, st, root, map[string]string{"mail": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, rootA := openTestStorage(t, cfg)
+	b, rootB := openTestStorage(t, cfg)
+	c, rootC := openTestStorage(t, cfg)
+	if err := store(t, a, rootA, map[string]string{"mail": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store(t, b, rootB, map[string]string{"mail": "b"}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("got %v, want %v", err, ErrConflict)
+	}
+	checkPasswords(t, cfg, map[string]string{"mail": "a"})
+
+	c.Resolve = func(conflict Conflict) (*Folder, error) {
+		if conflict.Key != "mail" || conflict.Ours.Pass != "c" || conflict.Theirs.Pass != "a" || conflict.Base.Pass != "1" {
+			t.Errorf("unexpected conflict %+v", conflict)
+		}
+		return conflict.Ours, nil
+	}
+	if err := store(t, c, rootC, map[string]string{"mail": "c"}); err != nil {
+		t.Fatal(err)
+	}
+	checkPasswords(t, cfg, map[string]string{"mail": "c"})
+}
+
+// TestStoreRekeyNotMerged checks a new key is never merged with revisions encrypted with the old one.
+func TestStoreRekeyNotMerged(t *testing.T) {
+	cfg := testConfig(t)
+	st, root := openTestStorage(t, cfg)
+	if err := store(t, st, root, map[string]string{"mail": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, rootA := openTestStorage(t, cfg)
+	b, rootB := openTestStorage(t, cfg)
+	if err := store(t, b, rootB, map[string]string{"bank": "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := GenerateAESKey(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Rekey(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store(t, a, rootA, nil); !errors.Is(err, ErrRemoteChanged) {
+		t.Fatalf("got %v, want %v", err, ErrRemoteChanged)
+	}
+	checkPasswords(t, cfg, map[string]string{"mail": "1", "bank": "2"})
+}
+
+// testRemote creates a bare repo with an empty commit and a git user for commits in a temporary home.
+func testRemote(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = tester\n\temail = t@e.st\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := filepath.Join(home, "remote.git")
+	if _, err := git.PlainInit(remote, true); err != nil {
+		t.Fatal(err)
+	}
+	seed, err := git.PlainInit(filepath.Join(home, "seed"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := seed.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Commit("init", &git.CommitOptions{
+		AllowEmptyCommits: true,
+		Author:            &object.Signature{Name: "tester", Email: "t@e.st"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seed.CreateRemote(&config.RemoteConfig{Name: remoteName, URLs: []string{remote}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := seed.Push(&git.PushOptions{RemoteName: remoteName}); err != nil {
+		t.Fatal(err)
+	}
+	return remote
+}
+
+func TestOfflineQueue(t *testing.T) {
+	remote := testRemote(t)
+	cfg := testConfig(t)
+	cfg.Backend, cfg.GitRepoPath = BackendGit, remote
+
+	st, root := openTestStorage(t, cfg)
+	if err := store(t, st, root, map[string]string{"mail": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a change made while the remote is unreachable stays in the local copy
+	if err := os.Rename(remote, remote+".off"); err != nil {
+		t.Fatal(err)
+	}
+	st, root = openTestStorage(t, cfg)
+	if !st.Offline {
+		t.Fatal("the storage is not offline")
+	}
+	if err := store(t, st, root, map[string]string{"bank": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := st.Pending(); err != nil || pending != 1 {
+		t.Fatalf("pending %d, %v, want 1", pending, err)
+	}
+	checkPasswords(t, cfg, map[string]string{"mail": "1", "bank": "2"})
+
+	// someone else with another working copy changes the remote meanwhile
+	if err := os.Rename(remote+".off", remote); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	st, root = openTestStorage(t, cfg)
+	if err := store(t, st, root, map[string]string{"shop": "3"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(os.Getenv("HOME"), ".cache"))
+
+	st, _ = openTestStorage(t, cfg)
+	pushed, err := st.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushed != 1 {
+		t.Errorf("%d commits pushed, want 1", pushed)
+	}
+	if pending, err := st.Pending(); err != nil || pending != 0 {
+		t.Errorf("pending %d, %v, want 0", pending, err)
+	}
+
+	// a new working copy sees the merged vault
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	checkPasswords(t, cfg, map[string]string{"mail": "1", "bank": "2", "shop": "3"})
+}
+
+// TestSyncRecipients checks Sync from a storage without loaded key material keeps the vault shared.
+func TestSyncRecipients(t *testing.T) {
+	remote := testRemote(t)
+	cfgA, cfgB := testConfig(t), testConfig(t)
+	for _, cfg := range []*Config{cfgA, cfgB} {
+		identity, err := GenerateIdentity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.IdentityPath = filepath.Join(t.TempDir(), "identity")
+		if err := os.WriteFile(cfg.IdentityPath, identity, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		cfg.Backend, cfg.GitRepoPath = BackendGit, remote
+	}
+	identityB, err := os.ReadFile(cfgB.IdentityPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubB, err := PublicKey(identityB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, root := openTestStorage(t, cfgA)
+	if err := st.Encrypt(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddRecipient("b", pubB); err != nil {
+		t.Fatal(err)
+	}
+	if err := store(t, st, root, map[string]string{"mail": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A commits while the remote is unreachable
+	if err := os.Rename(remote, remote+".off"); err != nil {
+		t.Fatal(err)
+	}
+	st, root = openTestStorage(t, cfgA)
+	if err := store(t, st, root, map[string]string{"bank": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(remote+".off", remote); err != nil {
+		t.Fatal(err)
+	}
+
+	// B changes the remote from another working copy
+	cache := os.Getenv("XDG_CACHE_HOME")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	st, root = openTestStorage(t, cfgB)
+	if err := store(t, st, root, map[string]string{"shop": "3"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CACHE_HOME", cache)
+
+	// passy sync merges without reading the vault first
+	st, err = New(cfgA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	want := map[string]string{"mail": "1", "bank": "2", "shop": "3"}
+	checkPasswords(t, cfgA, want)
+	checkPasswords(t, cfgB, want)
+}
+
+// TestKeyLengths checks the header names the AES variant of the key and it's checked on decrypt.
+func TestKeyLengths(t *testing.T) {
+	tests := []struct {
+		size int
+		id   byte
+	}{
+		{16, cipherIDAES128GCM},
+		{24, cipherIDAES192GCM},
+		{32, cipherIDAES256GCM},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(t)
+		key, err := GenerateAESKey(tt.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(cfg.PrivKeyPath, key, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		st, root := openTestStorage(t, cfg)
+		if err := store(t, st, root, map[string]string{"mail": "1"}); err != nil {
+			t.Fatal(err)
+		}
+		checkPasswords(t, cfg, map[string]string{"mail": "1"})
+
+		// the loaded data is base64 encoded
+		st, _ = openTestStorage(t, cfg)
+		data, err := base64.StdEncoding.DecodeString(st.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pos := len(formatMagic) + 2
+		if data[pos] != tt.id {
+			t.Errorf("%d byte key: cipher %d, want %d", tt.size, data[pos], tt.id)
+		}
+		data[pos] = cipherIDAES256GCM
+		if tt.id == cipherIDAES256GCM {
+			data[pos] = cipherIDAES128GCM
+		}
+		st.Data = base64.StdEncoding.EncodeToString(data)
+		if _, err := st.Decrypt(); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("%d byte key with cipher %d: got %v, want %v", tt.size, data[pos], err, ErrCorrupted)
+		}
+	}
+
+	st, _ := openTestStorage(t, testConfig(t))
+	if err := st.Rekey(make([]byte, 20)); err == nil {
+		t.Error("a 20 byte key is accepted")
+	}
+}